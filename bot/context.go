@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Variables フロー実行中にノード間で共有される変数ストア
+type Variables struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+// NewVariables 空の変数ストアを作成
+func NewVariables() *Variables {
+	return &Variables{
+		values: make(map[string]interface{}),
+	}
+}
+
+// Set 変数に値を設定します
+func (v *Variables) Set(name string, value interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[name] = value
+}
+
+// Get 変数の値を取得します
+func (v *Variables) Get(name string) (interface{}, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, ok := v.values[name]
+	return value, ok
+}
+
+// Delete 変数を削除します
+func (v *Variables) Delete(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.values, name)
+}
+
+// GetString 変数を文字列として取得します（存在しない場合は空文字）
+func (v *Variables) GetString(name string) string {
+	value, ok := v.Get(name)
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// GetInt 変数を整数として取得します
+func (v *Variables) GetInt(name string) (int, bool) {
+	value, ok := v.Get(name)
+	if !ok {
+		return 0, false
+	}
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// GetBool 変数を真偽値として取得します
+func (v *Variables) GetBool(name string) bool {
+	value, ok := v.Get(name)
+	if !ok {
+		return false
+	}
+	switch b := value.(type) {
+	case bool:
+		return b
+	case string:
+		parsed, err := strconv.ParseBool(b)
+		return err == nil && parsed
+	}
+	return false
+}
+
+// GetList 変数をリストとして取得します
+func (v *Variables) GetList(name string) []interface{} {
+	value, ok := v.Get(name)
+	if !ok {
+		return nil
+	}
+	switch list := value.(type) {
+	case []interface{}:
+		return list
+	case []string:
+		items := make([]interface{}, len(list))
+		for i, s := range list {
+			items[i] = s
+		}
+		return items
+	}
+	return []interface{}{value}
+}
+
+// Snapshot 現在の変数のコピーを返します
+func (v *Variables) Snapshot() map[string]interface{} {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	snapshot := make(map[string]interface{}, len(v.values))
+	for name, value := range v.values {
+		snapshot[name] = value
+	}
+	return snapshot
+}
+
+// TraceEntry 実行されたノード1件分の記録
+type TraceEntry struct {
+	NodeID string `json:"nodeId"`
+	Type   string `json:"type"`
+	// Scope ループ本体などで実行された場合の反復の位置（例: "forEach1#0"）
+	Scope      string     `json:"scope,omitempty"`
+	Result     NodeResult `json:"result"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt time.Time  `json:"finishedAt"`
+	// Children subflow ノードが呼び出したフロー内のノードの記録
+	Children []TraceEntry `json:"children,omitempty"`
+}
+
+// ExecutionContext 1回のフロー実行で共有される実行コンテキスト
+type ExecutionContext struct {
+	Context   context.Context
+	Variables *Variables
+
+	mu      sync.RWMutex
+	results map[string]NodeResult
+	trace   []TraceEntry
+}
+
+// simulationKey シミュレーション実行であることを示すコンテキストのキー
+type simulationKey struct{}
+
+// WithSimulation シミュレーション実行として ctx を返します
+// ノードはこのコンテキストでは外部サービスへの問い合わせやデータの保存を行わず、結果を仮の値で代用します
+func WithSimulation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, simulationKey{}, true)
+}
+
+// IsSimulation ctx がシミュレーション実行のものかを返します
+func IsSimulation(ctx context.Context) bool {
+	simulated, _ := ctx.Value(simulationKey{}).(bool)
+	return simulated
+}
+
+// NewExecutionContext 新しい実行コンテキストを作成
+func NewExecutionContext(ctx context.Context) *ExecutionContext {
+	return &ExecutionContext{
+		Context:   ctx,
+		Variables: NewVariables(),
+		results:   make(map[string]NodeResult),
+	}
+}
+
+// Result 実行済みノードの結果を取得します
+func (ec *ExecutionContext) Result(nodeID string) (NodeResult, bool) {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	result, ok := ec.results[nodeID]
+	return result, ok
+}
+
+// Output 実行済みノードの出力値を取得します
+func (ec *ExecutionContext) Output(nodeID, key string) (interface{}, bool) {
+	result, ok := ec.Result(nodeID)
+	if !ok || result.Output == nil {
+		return nil, false
+	}
+	value, ok := result.Output[key]
+	return value, ok
+}
+
+// Results これまでに実行された全ノードの結果のコピーを返します
+func (ec *ExecutionContext) Results() map[string]NodeResult {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	results := make(map[string]NodeResult, len(ec.results))
+	for id, result := range ec.results {
+		results[id] = result
+	}
+	return results
+}
+
+func (ec *ExecutionContext) setResult(nodeID string, result NodeResult) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.results[nodeID] = result
+}
+
+// Trace ノードの実行順に記録を返します
+func (ec *ExecutionContext) Trace() []TraceEntry {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	trace := make([]TraceEntry, len(ec.trace))
+	copy(trace, ec.trace)
+	return trace
+}
+
+func (ec *ExecutionContext) addTrace(entry TraceEntry) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.trace = append(ec.trace, entry)
+}
@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// DiscordClient ノードからDiscordへ行う操作を抽象化したインターフェース
+type DiscordClient interface {
+	// BotUserID 操作を行うボット自身のユーザーID
+	BotUserID() string
+	SendMessage(channelID, content string) (*discordgo.Message, error)
+	SendEmbeds(channelID string, embeds ...*discordgo.MessageEmbed) (*discordgo.Message, error)
+	SendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+	// EditMessage ボットが送信したメッセージの本文を書き換えます
+	EditMessage(channelID, messageID, content string) (*discordgo.Message, error)
+	Typing(channelID string) error
+	AddReaction(channelID, messageID, emoji string) error
+	Message(channelID, messageID string) (*discordgo.Message, error)
+	User(userID string) (*discordgo.User, error)
+	// Channel チャンネル（スレッドを含む）の情報を取得します
+	Channel(channelID string) (*discordgo.Channel, error)
+	// CreateThread メッセージを起点にスレッドを作成します
+	CreateThread(channelID, messageID, name string) (*discordgo.Channel, error)
+}
+
+type sessionClient struct {
+	session *discordgo.Session
+}
+
+// NewSessionClient discordgoのセッションを使うDiscordClientを作成
+func NewSessionClient(session *discordgo.Session) DiscordClient {
+	return &sessionClient{session: session}
+}
+
+func (c *sessionClient) BotUserID() string {
+	return c.session.State.User.ID
+}
+
+func (c *sessionClient) SendMessage(channelID, content string) (*discordgo.Message, error) {
+	return c.session.ChannelMessageSend(channelID, content)
+}
+
+func (c *sessionClient) SendEmbeds(channelID string, embeds ...*discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return c.session.ChannelMessageSendEmbeds(channelID, embeds)
+}
+
+func (c *sessionClient) SendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	return c.session.ChannelMessageSendComplex(channelID, data)
+}
+
+func (c *sessionClient) EditMessage(channelID, messageID, content string) (*discordgo.Message, error) {
+	return c.session.ChannelMessageEdit(channelID, messageID, content)
+}
+
+func (c *sessionClient) Typing(channelID string) error {
+	return c.session.ChannelTyping(channelID)
+}
+
+func (c *sessionClient) AddReaction(channelID, messageID, emoji string) error {
+	return c.session.MessageReactionAdd(channelID, messageID, emoji)
+}
+
+func (c *sessionClient) Message(channelID, messageID string) (*discordgo.Message, error) {
+	return c.session.ChannelMessage(channelID, messageID)
+}
+
+func (c *sessionClient) User(userID string) (*discordgo.User, error) {
+	return c.session.User(userID)
+}
+
+func (c *sessionClient) Channel(channelID string) (*discordgo.Channel, error) {
+	if channel, err := c.session.State.Channel(channelID); err == nil {
+		return channel, nil
+	}
+	return c.session.Channel(channelID)
+}
+
+func (c *sessionClient) CreateThread(channelID, messageID, name string) (*discordgo.Channel, error) {
+	return c.session.MessageThreadStart(channelID, messageID, name, 1440)
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// MaxMessageContent Discordのメッセージ本文の文字数の上限
+const MaxMessageContent = 2000
+
+// Discordの埋め込みの上限
+const (
+	MaxEmbedsPerMessage    = 10
+	MaxEmbedTitle          = 256
+	MaxEmbedDescription    = 4096
+	MaxEmbedFields         = 25
+	MaxEmbedFieldName      = 256
+	MaxEmbedFieldValue     = 1024
+	MaxEmbedFooterText     = 2048
+	MaxEmbedAuthorName     = 256
+	MaxEmbedTotalCharacter = 6000
+)
+
+// ValidateMessageContent 送信前にメッセージ本文がDiscordの上限に収まっているかを検証します
+func ValidateMessageContent(content string) error {
+	if n := len([]rune(content)); n > MaxMessageContent {
+		return fmt.Errorf("本文は%d文字までです（%d文字）", MaxMessageContent, n)
+	}
+	return nil
+}
+
+// ValidateEmbeds 送信前に埋め込みがDiscordの上限に収まっていて、空の埋め込みがないかを検証します
+// 文字数の合計は1メッセージ内のすべての埋め込みで数えます
+func ValidateEmbeds(embeds []*discordgo.MessageEmbed) error {
+	if len(embeds) > MaxEmbedsPerMessage {
+		return fmt.Errorf("埋め込みは1メッセージに%d個までです（%d個）", MaxEmbedsPerMessage, len(embeds))
+	}
+
+	var problems []string
+	check := func(field, value string, limit int) int {
+		n := len([]rune(value))
+		if n > limit {
+			problems = append(problems, fmt.Sprintf("%s は%d文字までです（%d文字）", field, limit, n))
+		}
+		return n
+	}
+
+	total := 0
+	for i, embed := range embeds {
+		prefix := fmt.Sprintf("embeds[%d]", i)
+		if isEmptyEmbed(embed) {
+			problems = append(problems, prefix+" に表示する内容がありません")
+		}
+		total += check(prefix+".title", embed.Title, MaxEmbedTitle)
+		total += check(prefix+".description", embed.Description, MaxEmbedDescription)
+		if embed.Footer != nil {
+			total += check(prefix+".footer.text", embed.Footer.Text, MaxEmbedFooterText)
+		}
+		if embed.Author != nil {
+			total += check(prefix+".author.name", embed.Author.Name, MaxEmbedAuthorName)
+		}
+		if len(embed.Fields) > MaxEmbedFields {
+			problems = append(problems, fmt.Sprintf("%s.fields は%d個までです（%d個）", prefix, MaxEmbedFields, len(embed.Fields)))
+		}
+		for j, field := range embed.Fields {
+			fieldPrefix := fmt.Sprintf("%s.fields[%d]", prefix, j)
+			if field.Name == "" || field.Value == "" {
+				problems = append(problems, fieldPrefix+" の名前と値は空にできません")
+			}
+			total += check(fieldPrefix+".name", field.Name, MaxEmbedFieldName)
+			total += check(fieldPrefix+".value", field.Value, MaxEmbedFieldValue)
+		}
+	}
+	if total > MaxEmbedTotalCharacter {
+		problems = append(problems, fmt.Sprintf("埋め込みの文字数の合計は%d文字までです（%d文字）", MaxEmbedTotalCharacter, total))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("埋め込みをDiscordに送信できません: %s", strings.Join(problems, "、"))
+	}
+	return nil
+}
+
+// isEmptyEmbed タイトル、説明、フィールド、画像、作成者、フッターのいずれもない埋め込みかを返します
+// Discordは表示する内容のない埋め込みを受け付けません
+func isEmptyEmbed(embed *discordgo.MessageEmbed) bool {
+	return embed.Title == "" && embed.Description == "" && len(embed.Fields) == 0 &&
+		embed.Image == nil && embed.Thumbnail == nil && embed.Author == nil && embed.Footer == nil
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestValidateEmbeds(t *testing.T) {
+	titled := func(title string) *discordgo.MessageEmbed {
+		return &discordgo.MessageEmbed{Title: title}
+	}
+	fields := func(n int) []*discordgo.MessageEmbedField {
+		list := make([]*discordgo.MessageEmbedField, n)
+		for i := range list {
+			list[i] = &discordgo.MessageEmbedField{Name: "n", Value: "v"}
+		}
+		return list
+	}
+	tenEmbeds := make([]*discordgo.MessageEmbed, MaxEmbedsPerMessage)
+	for i := range tenEmbeds {
+		tenEmbeds[i] = titled("t")
+	}
+
+	tests := []struct {
+		name   string
+		embeds []*discordgo.MessageEmbed
+		want   string
+	}{
+		{"title only", []*discordgo.MessageEmbed{titled("t")}, ""},
+		{"fields only", []*discordgo.MessageEmbed{{Fields: fields(1)}}, ""},
+		{"image only", []*discordgo.MessageEmbed{{Image: &discordgo.MessageEmbedImage{URL: "https://example.com/a.png"}}}, ""},
+		{"max embeds", tenEmbeds, ""},
+		{"empty embed", []*discordgo.MessageEmbed{titled("t"), {URL: "https://example.com", Color: 1}}, "embeds[1] に表示する内容がありません"},
+		{"too many embeds", append(tenEmbeds, titled("t")), "埋め込みは1メッセージに10個まで"},
+		{"long title", []*discordgo.MessageEmbed{titled(strings.Repeat("あ", MaxEmbedTitle+1))}, "embeds[0].title は256文字まで"},
+		{"too many fields", []*discordgo.MessageEmbed{{Fields: fields(MaxEmbedFields + 1)}}, "embeds[0].fields は25個まで"},
+		{"empty field", []*discordgo.MessageEmbed{{Fields: []*discordgo.MessageEmbedField{{Name: "n"}}}}, "embeds[0].fields[0] の名前と値は空にできません"},
+		{"total length", []*discordgo.MessageEmbed{
+			{Description: strings.Repeat("a", MaxEmbedDescription)},
+			{Description: strings.Repeat("a", MaxEmbedTotalCharacter-MaxEmbedDescription+1)},
+		}, "文字数の合計は6000文字まで"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEmbeds(tt.embeds)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("ValidateEmbeds: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ValidateEmbeds error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
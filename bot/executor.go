@@ -2,128 +2,468 @@
 package bot
 
 import (
+	"context"
 	"discord-bot-service/internal/models"
 	"errors"
 	"fmt"
-
-	"github.com/bwmarrin/discordgo"
+	"sync"
+	"time"
 )
 
 type NodeResult struct {
-	Type     string
-	Continue bool
+	Type     string `json:"type"`
+	Continue bool   `json:"continue"`
+	// Handle 発火した出力ハンドル名。空の場合はハンドル指定のないエッジのみを辿る
+	Handle string `json:"handle,omitempty"`
+	// Output 後続ノードから参照できるノードの出力値
+	Output map[string]interface{} `json:"output,omitempty"`
 }
 
 type NodeProps struct {
+	// Context ノードの実行時間の上限を含むコンテキスト
+	// 外部APIの呼び出しなどはこのコンテキストに従って中断してください
+	// タイムアウト時も実行関数が戻るまで後続の処理は待機するため、キャンセル後は速やかに戻る必要があります
+	Context context.Context
 	Node    models.Node
-	Message *discordgo.MessageCreate
-	Session *discordgo.Session
+	// Trigger フロー実行のきっかけとなったイベント
+	Trigger *Trigger
+	// Client Discordへの送信などの操作はすべてこちらを経由する
+	Client DiscordClient
+	// Exec 実行中のフロー全体で共有されるコンテキスト
+	Exec *ExecutionContext
+	// Sources このノードへ到達した接続元ノードのID。合流ノードでは到着したすべての接続元が入ります
+	Sources []string
+	// RunBranch 指定した出力ハンドルに接続されたノードを実行し、終了を待ちます
+	// ループ本体の実行に使用します。iteration ごとに新しいスコープで実行されるため、同じノードを繰り返し実行できます
+	RunBranch func(handle string, iteration int) error
+
+	// 以下はパッケージ内のノード（subflow など）が実行状態を参照するために使用します
+	run    *flowRun
+	nested *nestedTrace
 }
 
 // NodeExecutor 各ノードタイプの実行ロジックを定義する関数型
 type NodeExecutor func(NodeProps) (NodeResult, error)
 
+// NodeValidator ノードの設定を検証し、問題点を返す関数型
+type NodeValidator func(ctx context.Context, node models.Node) []models.ValidationError
+
+// NodeOption ノードタイプ登録時の追加設定
+type NodeOption func(*nodeRegistration)
+
+// WithValidator フロー保存時にノード設定を検証する関数を設定
+func WithValidator(validator NodeValidator) NodeOption {
+	return func(r *nodeRegistration) {
+		r.validator = validator
+	}
+}
+
+type nodeRegistration struct {
+	executor  NodeExecutor
+	validator NodeValidator
+	trigger   bool
+	matcher   TriggerMatcher
+	schema    *Schema
+	metadata  NodeMetadata
+	join      bool
+	// noDefaultTimeout ノードに指定がない場合も実行時間の上限を設けない
+	noDefaultTimeout bool
+}
+
+// WithoutDefaultTimeout 既定のタイムアウトを適用しない
+// 本体の実行を含むループなど、実行時間がノード自身で決まらない場合に使用します
+func WithoutDefaultTimeout() NodeOption {
+	return func(r *nodeRegistration) {
+		r.noDefaultTimeout = true
+	}
+}
+
 // FlowExecutor フロー全体の実行を管理する構造体
 type FlowExecutor struct {
-	nodeExecutors map[string]NodeExecutor
+	nodeExecutors map[string]*nodeRegistration
+	// NodeTimeout ノードにタイムアウトの指定がない場合の実行時間の上限。0の場合は無制限
+	NodeTimeout time.Duration
+	// MaxParallelism フローに上限の指定がない場合に並行して実行する分岐の数
+	MaxParallelism int
+	// Flows subflow ノードが呼び出すフローの取得元
+	Flows FlowLoader
+	// MaxSubflowDepth subflow ノードによる呼び出しの深さの上限。最初に実行したフローを含めて、同時に実行できるフローの数です
+	MaxSubflowDepth int
 }
 
+// HandleError ノードの実行に失敗した場合に辿る出力ハンドル
+// すべてのノードで使用でき、ノードの実行関数が返すハンドルとしては予約されています
+const HandleError = "error"
+
+// ErrNodeTimeout ノードの実行が時間内に終わらなかった場合のエラー
+var ErrNodeTimeout = errors.New("ノードの実行がタイムアウトしました")
+
 // NewFlowExecutor 新しいFlowExecutorインスタンスを作成
 func NewFlowExecutor() *FlowExecutor {
 	return &FlowExecutor{
-		nodeExecutors: make(map[string]NodeExecutor),
+		nodeExecutors:   make(map[string]*nodeRegistration),
+		NodeTimeout:     60 * time.Second,
+		MaxParallelism:  4,
+		MaxSubflowDepth: 5,
 	}
 }
 
 // RegisterNodeExecutor 特定のノードタイプに対する実行関数を登録
-func (fe *FlowExecutor) RegisterNodeExecutor(nodeType string, executor NodeExecutor) {
-	fe.nodeExecutors[nodeType] = executor
+func (fe *FlowExecutor) RegisterNodeExecutor(nodeType string, executor NodeExecutor, opts ...NodeOption) {
+	registration := &nodeRegistration{executor: executor}
+	for _, opt := range opts {
+		opt(registration)
+	}
+	fe.nodeExecutors[nodeType] = registration
 }
 
-// ExecuteFlow フロー全体を実行し、各ノードの結果を返します
-func (fe *FlowExecutor) ExecuteFlow(flow models.FlowData, m *discordgo.MessageCreate, s *discordgo.Session) (map[string]NodeResult, error) {
-	results := make(map[string]NodeResult)
-	visited := make(map[string]bool)
+// HasNodeType ノードタイプが登録済みかを返します
+func (fe *FlowExecutor) HasNodeType(nodeType string) bool {
+	_, ok := fe.nodeExecutors[nodeType]
+	return ok
+}
 
-	// スタートノードを探す
-	startNode, err := fe.findStartNode(flow.Nodes)
-	if err != nil {
-		return nil, err
+// IsTriggerType ノードタイプがトリガーノードとして登録されているかを返します
+func (fe *FlowExecutor) IsTriggerType(nodeType string) bool {
+	registration, ok := fe.nodeExecutors[nodeType]
+	return ok && registration.trigger
+}
+
+// flowRun 1回のフロー実行中の内部状態
+type flowRun struct {
+	executor *FlowExecutor
+	flow     models.FlowData
+	exec     *ExecutionContext
+	trigger  *Trigger
+	client   DiscordClient
+	// slots 並行実行の上限を管理するセマフォ。順次実行の場合は nil
+	// 実行を開始したゴルーチンが1つ分を使うため、容量は上限より1つ少ない
+	slots chan struct{}
+	// stack subflow ノードによる呼び出し元を含むフローのキー。最後がこのフロー
+	stack []string
+}
+
+func (fe *FlowExecutor) newRun(flow models.FlowData, exec *ExecutionContext, trigger *Trigger, client DiscordClient) *flowRun {
+	run := &flowRun{
+		executor: fe,
+		flow:     flow,
+		exec:     exec,
+		trigger:  trigger,
+		client:   client,
+		stack:    []string{flow.Key},
+	}
+	if flow.Settings.ExecutionMode == models.ExecutionParallel {
+		parallelism := flow.Settings.MaxParallelism
+		if parallelism <= 0 {
+			parallelism = fe.MaxParallelism
+		}
+		run.slots = make(chan struct{}, parallelism-1)
+	}
+	return run
+}
+
+// ExecuteFlow トリガーに反応するノードからフローを実行し、実行コンテキストを返します
+// エラーが発生した場合も、そこまでの実行記録を含む実行コンテキストを返します
+// ctx がキャンセルされると、実行中のノードを打ち切り以降のノードは実行しません
+func (fe *FlowExecutor) ExecuteFlow(ctx context.Context, flow models.FlowData, trigger *Trigger, client DiscordClient) (*ExecutionContext, error) {
+	exec := NewExecutionContext(ctx)
+	setTriggerVariables(exec.Variables, trigger)
+	run := fe.newRun(flow, exec, trigger, client)
+
+	// トリガーに反応するスタートノードを探す
+	startNodes := fe.MatchTrigger(flow, trigger)
+	if len(startNodes) == 0 {
+		return exec, ErrNoMatchingTrigger
 	}
 
 	// スタートノードから実行を開始
-	err = fe.executeNode(startNode, flow, visited, results, m, s)
-	if err != nil {
-		return nil, err
+	return exec, fe.executeScope(run, newScope(ctx, "", nil), "", startNodes)
+}
+
+// ErrNoMatchingTrigger フロー内にトリガーに反応するノードがない場合のエラー
+var ErrNoMatchingTrigger = errors.New("トリガーに反応するスタートノードが見つかりません")
+
+// MatchTrigger フロー内でトリガーに反応するスタートノードを返します
+func (fe *FlowExecutor) MatchTrigger(flow models.FlowData, trigger *Trigger) []models.Node {
+	var nodes []models.Node
+	if trigger == nil {
+		return nodes
 	}
+	for _, node := range flow.Nodes {
+		if node.Type != trigger.Type {
+			continue
+		}
+		registration, ok := fe.nodeExecutors[node.Type]
+		if !ok || !registration.trigger {
+			continue
+		}
+		if registration.matcher != nil && !registration.matcher(node, trigger) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
 
-	return results, nil
+// executeNodes 分岐先のノードを実行します
+// 並行実行の場合は空きがある限り別のゴルーチンで実行し、すべての分岐の終了を待ちます
+// 順次実行の場合は最初に失敗した分岐で打ち切ります
+func (fe *FlowExecutor) executeNodes(run *flowRun, sc *scope, from string, nodes []models.Node) error {
+	if run.slots == nil || len(nodes) < 2 {
+		for _, node := range nodes {
+			if err := fe.executeNode(run, sc, from, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	record := func(err error) {
+		if err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}
+	}
+	for _, node := range nodes {
+		node := node
+		select {
+		case run.slots <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-run.slots }()
+				record(fe.executeNode(run, sc, from, node))
+			}()
+		default:
+			// 上限に達している場合は呼び出し元のゴルーチンで実行する
+			// 呼び出し元は自身の枠を持っているため、上限を超えることはない
+			record(fe.executeNode(run, sc, from, node))
+		}
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
-// findStartNode はフロー内のスタートノードを探します
-func (fe *FlowExecutor) findStartNode(nodes []models.Node) (models.Node, error) {
+// executeScope スコープ内の分岐をすべて実行し、待ち合わせが完了しなかった合流ノードを到着した接続元だけで実行します
+// 到着しなかった接続元は、選ばれなかった分岐や後続へ進まなかったノードとして扱います
+func (fe *FlowExecutor) executeScope(run *flowRun, sc *scope, from string, nodes []models.Node) error {
+	if err := fe.executeNodes(run, sc, from, nodes); err != nil {
+		return err
+	}
+	// 合流ノードの先で別の合流ノードに到着する場合があるため、待ち合わせ中のものがなくなるまで繰り返す
+	for pending := sc.pendingJoins(); len(pending) > 0; pending = sc.pendingJoins() {
+		for _, id := range pending {
+			node, ok := findNode(run.flow.Nodes, id)
+			if !ok {
+				continue
+			}
+			if err := fe.runNode(run, sc, fe.nodeExecutors[node.Type], node, sc.arrivedAt(id)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findNode IDが一致するノードを返します
+func findNode(nodes []models.Node, id string) (models.Node, bool) {
 	for _, node := range nodes {
-		if node.Type == "start" {
-			return node, nil
+		if node.ID == id {
+			return node, true
 		}
 	}
-	return models.Node{}, errors.New("スタートノードが見つかりません")
+	return models.Node{}, false
 }
 
 // executeNode は単一のノードを実行し、次のノードへ進みます
-func (fe *FlowExecutor) executeNode(node models.Node, flow models.FlowData, visited map[string]bool, results map[string]NodeResult, m *discordgo.MessageCreate, s *discordgo.Session) error {
-	// ノードが既に訪問済みの場合はスキップ（循環参照対策）
-	if visited[node.ID] {
-		return nil
+// from はこのノードへ遷移してきた接続元ノードのID（スタートノードは空）
+func (fe *FlowExecutor) executeNode(run *flowRun, sc *scope, from string, node models.Node) error {
+	// 実行がキャンセルされている場合は次のノードへ進まない
+	if err := sc.ctx.Err(); err != nil {
+		return err
 	}
-	visited[node.ID] = true
 
 	// ノードタイプに対応する実行関数を取得
-	executor, ok := fe.nodeExecutors[node.Type]
+	registration, ok := fe.nodeExecutors[node.Type]
 	if !ok {
 		return fmt.Errorf("ノードタイプ %s に対応する実行関数が見つかりません", node.Type)
 	}
 
+	sources := []string{from}
+	if registration.join {
+		var ready bool
+		if sources, ready = sc.arrive(run.flow.Edges, node, from); !ready {
+			return nil
+		}
+	}
+	return fe.runNode(run, sc, registration, node, sources)
+}
+
+// runNode 接続元 sources からの遷移としてノードを実行し、次のノードへ進みます
+func (fe *FlowExecutor) runNode(run *flowRun, sc *scope, registration *nodeRegistration, node models.Node, sources []string) error {
+	// ノードが既に訪問済みの場合はスキップ（循環参照対策）
+	if !sc.visit(node.ID) {
+		return nil
+	}
+
 	// ノードを実行
-	result, err := executor(NodeProps{
-		Node:    node,
-		Message: m,
-		Session: s,
-	})
+	entry := TraceEntry{NodeID: node.ID, Type: node.Type, Scope: sc.name, StartedAt: time.Now()}
+	nested := &nestedTrace{}
+	result, err := fe.invokeNode(run, sc, registration, node, sources, nested)
+	entry.FinishedAt = time.Now()
+	entry.Result = result
+	entry.Children = nested.entries()
 	if err != nil {
+		entry.Error = err.Error()
+		// フロー全体が中断された場合はエラーハンドルへ進まない
+		if sc.ctx.Err() == nil && hasHandle(run.flow.Edges, node.ID, HandleError) {
+			entry.Result.Handle = HandleError
+			run.exec.addTrace(entry)
+			return fe.handleError(run, sc, node, err)
+		}
+		run.exec.addTrace(entry)
 		return err
 	}
-	results[node.ID] = result
+	run.exec.addTrace(entry)
+	run.exec.setResult(node.ID, result)
 
 	if !result.Continue {
 		return nil
 	}
 
 	// 次のノードを探して実行
+	nextNodes, err := fe.findNextNodes(node.ID, result.Handle, false, run.flow.Edges, run.flow.Nodes, run.exec.Variables)
+	if err != nil {
+		return err
+	}
+	return fe.executeNodes(run, sc, node.ID, nextNodes)
+}
 
-	nextNodes := fe.findNextNodes(node.ID, flow.Edges, flow.Nodes)
-	for _, nextNode := range nextNodes {
-		err := fe.executeNode(nextNode, flow, visited, results, m, s)
-		if err != nil {
-			return err
+// handleError 失敗したノードの "error" ハンドルに接続されたノードへ進みます
+// エラーの内容は error.message / error.nodeId / error.nodeType 変数で参照できます
+func (fe *FlowExecutor) handleError(run *flowRun, sc *scope, node models.Node, nodeErr error) error {
+	run.exec.Variables.Set("error.message", nodeErr.Error())
+	run.exec.Variables.Set("error.nodeId", node.ID)
+	run.exec.Variables.Set("error.nodeType", node.Type)
+
+	nextNodes, err := fe.findNextNodes(node.ID, HandleError, true, run.flow.Edges, run.flow.Nodes, run.exec.Variables)
+	if err != nil {
+		return err
+	}
+	// 条件式によって辿るエッジがなくなった場合は元のエラーで失敗させる
+	if len(nextNodes) == 0 {
+		return nodeErr
+	}
+	return fe.executeNodes(run, sc, node.ID, nextNodes)
+}
+
+// hasHandle ノードから指定した出力ハンドルのエッジが出ているかを返します
+func hasHandle(edges []models.Edge, nodeID, handle string) bool {
+	for _, edge := range edges {
+		if edge.Source == nodeID && edge.SourceHandle == handle {
+			return true
 		}
 	}
+	return false
+}
 
-	return nil
+// runBranch ノードの出力ハンドルに接続されたノードを新しいスコープで実行します
+// ハンドル名が一致するエッジだけを辿り、分岐の終了を待って戻ります
+func (fe *FlowExecutor) runBranch(ctx context.Context, run *flowRun, sc *scope, node models.Node, handle string, iteration int) error {
+	nextNodes, err := fe.findNextNodes(node.ID, handle, true, run.flow.Edges, run.flow.Nodes, run.exec.Variables)
+	if err != nil {
+		return err
+	}
+	child := sc.child(ctx, fmt.Sprintf("%s#%d", node.ID, iteration))
+	return fe.executeScope(run, child, node.ID, nextNodes)
+}
+
+// invokeNode 実行時間の上限を設けてノードの実行関数を呼び出します
+// 上限を超えた場合は実行関数にキャンセルを伝え、戻るのを待ってから ErrNodeTimeout を返します
+func (fe *FlowExecutor) invokeNode(run *flowRun, sc *scope, registration *nodeRegistration, node models.Node, sources []string, nested *nestedTrace) (NodeResult, error) {
+	timeout := fe.NodeTimeout
+	if registration.noDefaultTimeout {
+		timeout = 0
+	}
+	if node.Data.Timeout > 0 {
+		timeout = time.Duration(node.Data.Timeout * float64(time.Second))
+	}
+	ctx, cancel := context.WithCancel(sc.ctx)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(sc.ctx, timeout)
+	}
+	defer cancel()
+
+	type outcome struct {
+		result NodeResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := registration.executor(NodeProps{
+			Context: ctx,
+			Node:    node,
+			Trigger: run.trigger,
+			Client:  run.client,
+			Exec:    run.exec,
+			Sources: sources,
+			RunBranch: func(handle string, iteration int) error {
+				return fe.runBranch(ctx, run, sc, node, handle, iteration)
+			},
+			run:    run,
+			nested: nested,
+		})
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		// タイムアウト後もノードが動き続けないよう、実行関数が中断して戻るのを待つ
+		cancel()
+		<-done
+		// フロー全体（または外側のループ）のキャンセルと区別する
+		if sc.ctx.Err() == nil {
+			return NodeResult{Type: node.Type}, fmt.Errorf("%w (%s)", ErrNodeTimeout, timeout)
+		}
+		return NodeResult{Type: node.Type}, sc.ctx.Err()
+	}
 }
 
 // findNextNodes は現在のノードから接続されている次のノードを探します
-func (fe *FlowExecutor) findNextNodes(nodeID string, edges []models.Edge, nodes []models.Node) []models.Node {
+// 出力ハンドルが一致し、条件式を満たすエッジのみを辿ります
+// strict が false の場合はハンドル指定のないエッジも辿ります
+func (fe *FlowExecutor) findNextNodes(nodeID, handle string, strict bool, edges []models.Edge, nodes []models.Node, vars *Variables) ([]models.Node, error) {
 	var nextNodes []models.Node
 	for _, edge := range edges {
-		if edge.Source == nodeID {
-			for _, node := range nodes {
-				if node.ID == edge.Target {
-					nextNodes = append(nextNodes, node)
-					break
-				}
+		if edge.Source != nodeID {
+			continue
+		}
+		if (strict || edge.SourceHandle != "") && edge.SourceHandle != handle {
+			continue
+		}
+		if edge.Condition != "" {
+			ok, err := EvalCondition(edge.Condition, vars)
+			if err != nil {
+				return nil, fmt.Errorf("エッジ %s の条件式の評価に失敗しました: %w", edge.ID, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		for _, node := range nodes {
+			if node.ID == edge.Target {
+				nextNodes = append(nextNodes, node)
+				break
 			}
 		}
 	}
-	return nextNodes
+	return nextNodes, nil
 }
@@ -0,0 +1,731 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discord-bot-service/internal/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// newTestExecutor テスト用のノードを登録したFlowExecutorを作成します
+//
+//	say    config.text の変数を展開し、トリガーのチャンネルへ送信する
+//	pick   config.handle の出力ハンドルへ進む
+//	fail   config.message のエラーで失敗する
+//	stop   後続へ進まない
+//	set    config.name の変数に config.value の変数を展開して格納する
+//	repeat config.times 回 body の先を実行し、done へ進む
+func newTestExecutor() *FlowExecutor {
+	fe := NewFlowExecutor()
+	fe.RegisterNodeExecutor("start", TriggerNodeExecutor, WithTriggerMatcher(nil))
+	fe.RegisterNodeExecutor(TriggerSubflow, TriggerNodeExecutor, WithTriggerMatcher(nil))
+	fe.RegisterNodeExecutor("say", func(props NodeProps) (NodeResult, error) {
+		text := expand(configString(props.Node, "text"), props.Exec.Variables)
+		if _, err := props.Client.SendMessage(props.Trigger.ChannelID, text); err != nil {
+			return NodeResult{}, err
+		}
+		return NodeResult{Type: "say", Continue: true, Output: map[string]interface{}{"text": text}}, nil
+	})
+	fe.RegisterNodeExecutor("pick", func(props NodeProps) (NodeResult, error) {
+		return NodeResult{Type: "pick", Continue: true, Handle: configString(props.Node, "handle")}, nil
+	})
+	fe.RegisterNodeExecutor("fail", func(props NodeProps) (NodeResult, error) {
+		return NodeResult{}, errors.New(configString(props.Node, "message"))
+	})
+	fe.RegisterNodeExecutor("stop", func(props NodeProps) (NodeResult, error) {
+		return NodeResult{Type: "stop"}, nil
+	})
+	fe.RegisterNodeExecutor("set", func(props NodeProps) (NodeResult, error) {
+		props.Exec.Variables.Set(configString(props.Node, "name"), expand(configString(props.Node, "value"), props.Exec.Variables))
+		return NodeResult{Type: "set", Continue: true}, nil
+	})
+	fe.RegisterNodeExecutor("repeat", func(props NodeProps) (NodeResult, error) {
+		times, _ := props.Node.Data.Config["times"].(int)
+		for i := 0; i < times; i++ {
+			props.Exec.Variables.Set("i", i)
+			if err := props.RunBranch("body", i); err != nil {
+				return NodeResult{}, err
+			}
+		}
+		return NodeResult{Type: "repeat", Continue: true, Handle: "done"}, nil
+	}, WithoutDefaultTimeout())
+	fe.RegisterNodeExecutor(NodeTypeJoin, JoinNodeExecutor, WithJoin())
+	fe.RegisterNodeExecutor(NodeTypeSubflow, SubflowNodeExecutor, WithoutDefaultTimeout())
+	return fe
+}
+
+func configString(node models.Node, key string) string {
+	value, _ := node.Data.Config[key].(string)
+	return value
+}
+
+// placeholder テスト用ノードの設定で使う {{.name}} 形式の変数参照
+var placeholder = regexp.MustCompile(`\{\{\.([\w.]+)\}\}`)
+
+// expand 文字列中の変数参照を変数の値で置き換えます
+func expand(text string, vars *Variables) string {
+	return placeholder.ReplaceAllStringFunc(text, func(ref string) string {
+		value, _ := LookupVariable(vars, placeholder.FindStringSubmatch(ref)[1])
+		return fmt.Sprint(value)
+	})
+}
+
+func testNode(id, nodeType string, config map[string]interface{}) models.Node {
+	return models.Node{ID: id, Type: nodeType, Data: models.NodeData{Config: config}}
+}
+
+func testEdge(source, target, handle string) models.Edge {
+	return models.Edge{ID: source + "-" + target, Source: source, Target: target, SourceHandle: handle}
+}
+
+func say(id, text string) models.Node {
+	return testNode(id, "say", map[string]interface{}{"text": text})
+}
+
+func testTrigger() *Trigger {
+	return &Trigger{
+		Type:      "start",
+		ID:        "m1",
+		ChannelID: "c1",
+		Content:   "hello",
+		Author:    &discordgo.User{ID: "u1", Username: "alice"},
+	}
+}
+
+func runTestFlow(t *testing.T, fe *FlowExecutor, flow models.FlowData) (*FakeClient, *ExecutionContext, error) {
+	t.Helper()
+	if flow.Key == "" {
+		flow.Key = "test"
+	}
+	client := NewFakeClient("bot")
+	exec, err := fe.ExecuteFlow(context.Background(), flow, testTrigger(), client)
+	return client, exec, err
+}
+
+// sentMessages 送信されたメッセージの本文を順番に返します
+func sentMessages(client *FakeClient) []string {
+	var contents []string
+	for _, action := range client.Actions() {
+		if action.Type == "sendMessage" {
+			contents = append(contents, action.Content)
+		}
+	}
+	return contents
+}
+
+func TestExecuteFlowFollowsSelectedHandle(t *testing.T) {
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("pick", "pick", map[string]interface{}{"handle": "b"}),
+			say("a", "A"),
+			say("b", "B"),
+			say("after", "after"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "pick", ""),
+			testEdge("pick", "a", "a"),
+			testEdge("pick", "b", "b"),
+			testEdge("b", "after", ""),
+		},
+	}
+
+	client, _, err := runTestFlow(t, newTestExecutor(), flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"B", "after"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteFlowEdgeCondition(t *testing.T) {
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			say("greet", "hi {{.trigger.authorName}}"),
+			say("other", "other"),
+		},
+		Edges: []models.Edge{
+			{ID: "e1", Source: "start", Target: "greet", Condition: `trigger.content == "hello"`},
+			{ID: "e2", Source: "start", Target: "other", Condition: `trigger.content == "bye"`},
+		},
+	}
+
+	client, _, err := runTestFlow(t, newTestExecutor(), flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"hi alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteFlowErrorHandle(t *testing.T) {
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("broken", "fail", map[string]interface{}{"message": "boom"}),
+			say("next", "unreachable"),
+			say("recover", "{{.error.nodeType}} {{.error.nodeId}}: {{.error.message}}"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "broken", ""),
+			testEdge("broken", "next", ""),
+			testEdge("broken", "recover", HandleError),
+		},
+	}
+
+	client, exec, err := runTestFlow(t, newTestExecutor(), flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"fail broken: boom"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	var entry *TraceEntry
+	for _, e := range exec.Trace() {
+		if e.NodeID == "broken" {
+			e := e
+			entry = &e
+		}
+	}
+	if entry == nil || entry.Error != "boom" || entry.Result.Handle != HandleError {
+		t.Errorf("trace entry for failed node = %+v", entry)
+	}
+}
+
+func TestExecuteFlowErrorWithoutHandle(t *testing.T) {
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("broken", "fail", map[string]interface{}{"message": "boom"}),
+			say("next", "unreachable"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "broken", ""),
+			testEdge("broken", "next", ""),
+		},
+	}
+
+	client, _, err := runTestFlow(t, newTestExecutor(), flow)
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	if got := sentMessages(client); len(got) != 0 {
+		t.Errorf("messages = %q, want none", got)
+	}
+}
+
+func TestExecuteFlowNodeTimeout(t *testing.T) {
+	fe := newTestExecutor()
+	var finished atomic.Bool
+	fe.RegisterNodeExecutor("slow", func(props NodeProps) (NodeResult, error) {
+		select {
+		case <-props.Context.Done():
+			// 後片付けに時間がかかるノードでも、戻るまで実行は終わらない
+			time.Sleep(20 * time.Millisecond)
+			finished.Store(true)
+			return NodeResult{}, props.Context.Err()
+		case <-time.After(time.Second):
+			return NodeResult{Type: "slow", Continue: true}, nil
+		}
+	})
+	slow := testNode("slow", "slow", nil)
+	slow.Data.Timeout = 0.02
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			slow,
+			say("timeout", "timed out: {{.error.nodeId}}"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "slow", ""),
+			testEdge("slow", "timeout", HandleError),
+		},
+	}
+
+	client, exec, err := runTestFlow(t, fe, flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if !finished.Load() {
+		t.Error("ExecuteFlow returned before the timed out node finished")
+	}
+	if got, want := sentMessages(client), []string{"timed out: slow"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if got := exec.Variables.GetString("error.message"); !strings.HasPrefix(got, ErrNodeTimeout.Error()) {
+		t.Errorf("error.message = %q, want the timeout error", got)
+	}
+}
+
+func TestExecuteFlowNodeTimeoutWithoutHandle(t *testing.T) {
+	fe := newTestExecutor()
+	fe.NodeTimeout = 20 * time.Millisecond
+	fe.RegisterNodeExecutor("slow", func(props NodeProps) (NodeResult, error) {
+		<-props.Context.Done()
+		return NodeResult{}, props.Context.Err()
+	})
+	flow := models.FlowData{
+		Nodes: []models.Node{testNode("start", "start", nil), testNode("slow", "slow", nil)},
+		Edges: []models.Edge{testEdge("start", "slow", "")},
+	}
+
+	_, _, err := runTestFlow(t, fe, flow)
+	if !errors.Is(err, ErrNodeTimeout) {
+		t.Fatalf("err = %v, want ErrNodeTimeout", err)
+	}
+}
+
+func joinFlow(mode string) models.FlowData {
+	return models.FlowData{
+		Settings: models.FlowSettings{ExecutionMode: mode},
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			say("a", "A"),
+			say("b", "B"),
+			testNode("join", NodeTypeJoin, nil),
+			say("done", "{{.join.a.text}}+{{.join.b.text}}"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "a", ""),
+			testEdge("start", "b", ""),
+			testEdge("a", "join", ""),
+			testEdge("b", "join", ""),
+			testEdge("join", "done", ""),
+		},
+	}
+}
+
+func TestExecuteFlowJoinAll(t *testing.T) {
+	for _, mode := range []string{models.ExecutionSequential, models.ExecutionParallel} {
+		t.Run(mode, func(t *testing.T) {
+			client, _, err := runTestFlow(t, newTestExecutor(), joinFlow(mode))
+			if err != nil {
+				t.Fatalf("ExecuteFlow: %v", err)
+			}
+			messages := sentMessages(client)
+			if len(messages) != 3 || messages[2] != "A+B" {
+				t.Errorf("messages = %q, want A and B followed by A+B", messages)
+			}
+		})
+	}
+}
+
+func TestExecuteFlowJoinAny(t *testing.T) {
+	flow := joinFlow(models.ExecutionSequential)
+	flow.Nodes[3].Data.Config = map[string]interface{}{"mode": JoinAny}
+	flow.Nodes[4] = say("done", "done")
+
+	client, exec, err := runTestFlow(t, newTestExecutor(), flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"A", "done", "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if result, _ := exec.Result("join"); len(result.Output) != 1 {
+		t.Errorf("join output = %v, want only the first branch", result.Output)
+	}
+}
+
+func TestExecuteFlowJoinUnreachedSource(t *testing.T) {
+	tests := map[string]models.FlowData{
+		"exclusive branches": {
+			Nodes: []models.Node{
+				testNode("start", "start", nil),
+				testNode("if", "pick", map[string]interface{}{"handle": "true"}),
+				say("a", "A"),
+				say("b", "B"),
+				testNode("join", NodeTypeJoin, nil),
+				say("done", "done"),
+			},
+			Edges: []models.Edge{
+				testEdge("start", "if", ""),
+				testEdge("if", "a", "true"),
+				testEdge("if", "b", "false"),
+				testEdge("a", "join", ""),
+				testEdge("b", "join", ""),
+				testEdge("join", "done", ""),
+			},
+		},
+		"stopped branch": {
+			Nodes: []models.Node{
+				testNode("start", "start", nil),
+				say("a", "A"),
+				testNode("b", "stop", nil),
+				testNode("join", NodeTypeJoin, nil),
+				say("done", "done"),
+			},
+			Edges: []models.Edge{
+				testEdge("start", "a", ""),
+				testEdge("start", "b", ""),
+				testEdge("a", "join", ""),
+				testEdge("b", "join", ""),
+				testEdge("join", "done", ""),
+			},
+		},
+	}
+	for name, flow := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, exec, err := runTestFlow(t, newTestExecutor(), flow)
+			if err != nil {
+				t.Fatalf("ExecuteFlow: %v", err)
+			}
+			// 到着しなかった接続元は待たずに、到着した分岐だけで合流する
+			if got, want := sentMessages(client), []string{"A", "done"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("messages = %q, want %q", got, want)
+			}
+			result, _ := exec.Result("join")
+			if _, ok := result.Output["a"]; !ok || len(result.Output) != 1 {
+				t.Errorf("join output = %v, want only the arrived branch", result.Output)
+			}
+		})
+	}
+}
+
+func TestExecuteFlowChainedJoinsWithUnreachedSources(t *testing.T) {
+	// 1つ目の合流ノードが待ち合わせを終えてから、2つ目の合流ノードに到着する
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			say("a", "A"),
+			testNode("b", "stop", nil),
+			testNode("join1", NodeTypeJoin, nil),
+			testNode("c", "stop", nil),
+			testNode("join2", NodeTypeJoin, nil),
+			say("done", "done"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "a", ""),
+			testEdge("start", "b", ""),
+			testEdge("start", "c", ""),
+			testEdge("a", "join1", ""),
+			testEdge("b", "join1", ""),
+			testEdge("join1", "join2", ""),
+			testEdge("c", "join2", ""),
+			testEdge("join2", "done", ""),
+		},
+	}
+	client, _, err := runTestFlow(t, newTestExecutor(), flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"A", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestValidateFlowExclusiveJoin(t *testing.T) {
+	fe := newTestExecutor()
+	flow := models.FlowData{
+		Key: "test",
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("if", "pick", map[string]interface{}{"handle": "true"}),
+			say("a", "A"),
+			say("b", "B"),
+			testNode("join", NodeTypeJoin, nil),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "if", ""),
+			testEdge("if", "a", "true"),
+			testEdge("if", "b", "false"),
+			testEdge("a", "join", ""),
+			testEdge("b", "join", ""),
+		},
+	}
+
+	// 選ばれなかった分岐は待たないため、同時に実行されない分岐を合流させてもよい
+	if errs := fe.ValidateFlow(context.Background(), flow); len(errs) != 0 {
+		t.Errorf("errs = %+v, want none", errs)
+	}
+
+	flow.Edges = flow.Edges[:4]
+	errs := fe.ValidateFlow(context.Background(), flow)
+	if len(errs) != 1 || errs[0].NodeID != "join" {
+		t.Errorf("single source: errs = %+v, want one error on the join node", errs)
+	}
+}
+
+func TestExecuteFlowMaxParallelism(t *testing.T) {
+	fe := newTestExecutor()
+	var (
+		mu            sync.Mutex
+		running, peak int
+		count         int
+	)
+	fe.RegisterNodeExecutor("work", func(props NodeProps) (NodeResult, error) {
+		mu.Lock()
+		running++
+		count++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return NodeResult{Type: "work"}, nil
+	})
+
+	flow := models.FlowData{
+		Settings: models.FlowSettings{ExecutionMode: models.ExecutionParallel, MaxParallelism: 2},
+		Nodes:    []models.Node{testNode("start", "start", nil)},
+	}
+	for i := 0; i < 6; i++ {
+		id := fmt.Sprintf("work%d", i)
+		flow.Nodes = append(flow.Nodes, testNode(id, "work", nil))
+		flow.Edges = append(flow.Edges, testEdge("start", id, ""))
+	}
+
+	if _, _, err := runTestFlow(t, fe, flow); err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("executed %d nodes, want 6", count)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", peak)
+	}
+}
+
+func TestExecuteFlowLoop(t *testing.T) {
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("loop", "repeat", map[string]interface{}{"times": 3}),
+			say("body", "item {{.i}}"),
+			say("done", "done"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "loop", ""),
+			testEdge("loop", "body", "body"),
+			testEdge("loop", "done", "done"),
+		},
+	}
+
+	client, exec, err := runTestFlow(t, newTestExecutor(), flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"item 0", "item 1", "item 2", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	var scopes []string
+	for _, entry := range exec.Trace() {
+		if entry.NodeID == "body" {
+			scopes = append(scopes, entry.Scope)
+		}
+	}
+	if want := []string{"loop#0", "loop#1", "loop#2"}; !reflect.DeepEqual(scopes, want) {
+		t.Errorf("body scopes = %q, want %q", scopes, want)
+	}
+}
+
+// testFlows subflow ノードが呼び出すフローをキーで返すFlowLoader
+type testFlows map[string]models.FlowData
+
+func (f testFlows) GetPublishedFlow(ctx context.Context, key string) (*models.FlowData, error) {
+	flow, ok := f[key]
+	if !ok {
+		return nil, fmt.Errorf("flow %s not found", key)
+	}
+	return &flow, nil
+}
+
+func TestExecuteFlowSubflow(t *testing.T) {
+	fe := newTestExecutor()
+	fe.Flows = testFlows{
+		"greeting": {
+			Key: "greeting",
+			Nodes: []models.Node{
+				testNode("start", TriggerSubflow, nil),
+				testNode("set", "set", map[string]interface{}{"name": "message", "value": "hello {{.name}}"}),
+				say("inner", "from subflow"),
+			},
+			Edges: []models.Edge{
+				testEdge("start", "set", ""),
+				testEdge("set", "inner", ""),
+			},
+		},
+	}
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("call", NodeTypeSubflow, map[string]interface{}{
+				"flowKey": "greeting",
+				"inputs":  map[string]interface{}{"name": "trigger.authorName"},
+				"outputs": map[string]interface{}{"greeting": "message"},
+			}),
+			say("reply", "{{.greeting}}"),
+		},
+		Edges: []models.Edge{
+			testEdge("start", "call", ""),
+			testEdge("call", "reply", ""),
+		},
+	}
+
+	client, exec, err := runTestFlow(t, fe, flow)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"from subflow", "hello alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	for _, entry := range exec.Trace() {
+		if entry.NodeID == "call" && len(entry.Children) != 3 {
+			t.Errorf("subflow trace has %d entries, want 3", len(entry.Children))
+		}
+	}
+}
+
+func TestExecuteFlowSubflowDepth(t *testing.T) {
+	fe := newTestExecutor()
+	recursive := models.FlowData{
+		Key: "recursive",
+		Nodes: []models.Node{
+			testNode("start", TriggerSubflow, nil),
+			testNode("call", NodeTypeSubflow, map[string]interface{}{"flowKey": "recursive"}),
+		},
+		Edges: []models.Edge{testEdge("start", "call", "")},
+	}
+	fe.Flows = testFlows{"recursive": recursive}
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("call", NodeTypeSubflow, map[string]interface{}{"flowKey": "recursive"}),
+		},
+		Edges: []models.Edge{testEdge("start", "call", "")},
+	}
+
+	_, _, err := runTestFlow(t, fe, flow)
+	if !errors.Is(err, ErrSubflowDepth) {
+		t.Fatalf("err = %v, want ErrSubflowDepth", err)
+	}
+}
+
+func TestExecuteFlowSubflowDepthLimit(t *testing.T) {
+	// test → f1 → f2 → f3 の順に呼び出す
+	chain := func(key, next string) models.FlowData {
+		flow := models.FlowData{
+			Key:   key,
+			Nodes: []models.Node{testNode("start", TriggerSubflow, nil), say("say", key)},
+			Edges: []models.Edge{testEdge("start", "say", "")},
+		}
+		if next != "" {
+			flow.Nodes = append(flow.Nodes, testNode("call", NodeTypeSubflow, map[string]interface{}{"flowKey": next}))
+			flow.Edges = append(flow.Edges, testEdge("say", "call", ""))
+		}
+		return flow
+	}
+	flows := testFlows{"f1": chain("f1", "f2"), "f2": chain("f2", "f3"), "f3": chain("f3", "")}
+	flow := models.FlowData{
+		Nodes: []models.Node{
+			testNode("start", "start", nil),
+			testNode("call", NodeTypeSubflow, map[string]interface{}{"flowKey": "f1"}),
+		},
+		Edges: []models.Edge{testEdge("start", "call", "")},
+	}
+
+	// 最初のフローを含めて4つのフローが同時に実行される
+	fe := newTestExecutor()
+	fe.Flows = flows
+	fe.MaxSubflowDepth = 4
+	client, _, err := runTestFlow(t, fe, flow)
+	if err != nil {
+		t.Fatalf("at the limit: ExecuteFlow: %v", err)
+	}
+	if got, want := sentMessages(client), []string{"f1", "f2", "f3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	fe.MaxSubflowDepth = 3
+	client, _, err = runTestFlow(t, fe, flow)
+	if !errors.Is(err, ErrSubflowDepth) {
+		t.Fatalf("over the limit: err = %v, want ErrSubflowDepth", err)
+	}
+	if got, want := sentMessages(client), []string{"f1", "f2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteFlowSubflowSharesParallelism(t *testing.T) {
+	fe := newTestExecutor()
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	fe.RegisterNodeExecutor("work", func(props NodeProps) (NodeResult, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return NodeResult{Type: "work"}, nil
+	})
+
+	parallel := models.FlowSettings{ExecutionMode: models.ExecutionParallel, MaxParallelism: 2}
+	sub := models.FlowData{Key: "sub", Settings: parallel, Nodes: []models.Node{testNode("start", TriggerSubflow, nil)}}
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("work%d", i)
+		sub.Nodes = append(sub.Nodes, testNode(id, "work", nil))
+		sub.Edges = append(sub.Edges, testEdge("start", id, ""))
+	}
+	fe.Flows = testFlows{"sub": sub}
+	flow := models.FlowData{Settings: parallel, Nodes: []models.Node{testNode("start", "start", nil)}}
+	for i := 0; i < 2; i++ {
+		id := fmt.Sprintf("call%d", i)
+		flow.Nodes = append(flow.Nodes, testNode(id, NodeTypeSubflow, map[string]interface{}{"flowKey": "sub"}))
+		flow.Edges = append(flow.Edges, testEdge("start", id, ""))
+	}
+
+	if _, _, err := runTestFlow(t, fe, flow); err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2 across the parent and its subflows", peak)
+	}
+}
+
+func TestExecuteFlowSimulation(t *testing.T) {
+	fe := newTestExecutor()
+	var simulated []bool
+	fe.RegisterNodeExecutor("probe", func(props NodeProps) (NodeResult, error) {
+		simulated = append(simulated, IsSimulation(props.Context))
+		return NodeResult{Type: "probe"}, nil
+	})
+	flow := models.FlowData{
+		Key:   "test",
+		Nodes: []models.Node{testNode("start", "start", nil), testNode("probe", "probe", nil)},
+		Edges: []models.Edge{testEdge("start", "probe", "")},
+	}
+
+	for _, ctx := range []context.Context{context.Background(), WithSimulation(context.Background())} {
+		if _, err := fe.ExecuteFlow(ctx, flow, testTrigger(), NewFakeClient("bot")); err != nil {
+			t.Fatalf("ExecuteFlow: %v", err)
+		}
+	}
+	if want := []bool{false, true}; !reflect.DeepEqual(simulated, want) {
+		t.Errorf("IsSimulation in node = %v, want %v", simulated, want)
+	}
+}
@@ -0,0 +1,555 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression はフロー変数に対して評価できる条件式
+//
+// 対応している構文:
+//
+//	リテラル      "text" 'text' 123 1.5 true false null
+//	変数参照      trigger.content dify.answer
+//	比較          == != < <= > >=
+//	文字列演算    contains startsWith endsWith matches
+//	論理演算      && || ! と括弧
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// ParseExpression 条件式を構文解析します
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("式の位置 %d に不正なトークン %q があります", p.peek().pos, p.peek().text)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// String 元の式を返します
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval 式を評価して値を返します
+func (e *Expression) Eval(vars *Variables) (interface{}, error) {
+	return e.root.eval(vars)
+}
+
+// EvalBool 式を評価して真偽値として返します
+func (e *Expression) EvalBool(vars *Variables) (bool, error) {
+	value, err := e.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value), nil
+}
+
+// EvalCondition 条件式を構文解析して真偽値として評価します
+func EvalCondition(source string, vars *Variables) (bool, error) {
+	expr, err := ParseExpression(source)
+	if err != nil {
+		return false, err
+	}
+	return expr.EvalBool(vars)
+}
+
+// LookupVariable ドット区切りの名前で変数を参照します
+// 完全一致する変数がない場合は、前方一致する変数のマップを辿ります
+func LookupVariable(vars *Variables, name string) (interface{}, bool) {
+	if value, ok := vars.Get(name); ok {
+		return value, true
+	}
+	for i := strings.LastIndex(name, "."); i > 0; i = strings.LastIndex(name[:i], ".") {
+		value, ok := vars.Get(name[:i])
+		if !ok {
+			continue
+		}
+		for _, key := range strings.Split(name[i+1:], ".") {
+			m, isMap := value.(map[string]interface{})
+			if !isMap {
+				return nil, false
+			}
+			if value, ok = m[key]; !ok {
+				return nil, false
+			}
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var wordOperators = map[string]bool{
+	"contains":   true,
+	"startsWith": true,
+	"endsWith":   true,
+	"matches":    true,
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("式の位置 %d の文字列が閉じられていません", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && !endsOperand(tokens)):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			kind := tokIdent
+			if wordOperators[text] {
+				kind = tokOperator
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "&&", "||":
+				tokens = append(tokens, token{kind: tokOperator, text: two, pos: start})
+				i += 2
+				continue
+			}
+			switch r {
+			case '<', '>', '!':
+				tokens = append(tokens, token{kind: tokOperator, text: string(r), pos: start})
+				i++
+			default:
+				return nil, fmt.Errorf("式の位置 %d に不正な文字 %q があります", start, r)
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// endsOperand は直前のトークンが値で終わっているか（マイナスを演算子とみなすか）を判定します
+func endsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[len(tokens)-1].kind {
+	case tokIdent, tokString, tokNumber, tokRParen:
+		return true
+	}
+	return false
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOperator && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOperator && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOperator || t.text == "&&" || t.text == "||" || t.text == "!" {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	node := &compareNode{op: t.text, left: left, right: right}
+	if t.text == "matches" {
+		if lit, ok := right.(*literalNode); ok {
+			pattern, isString := lit.value.(string)
+			if !isString {
+				return nil, fmt.Errorf("matches の右辺は文字列である必要があります")
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("正規表現 %q が不正です: %w", pattern, err)
+			}
+			node.re = re
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if t := p.peek(); t.kind == tokOperator && t.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("式の位置 %d の括弧が閉じられていません", t.pos)
+		}
+		return inner, nil
+	case tokString:
+		return &literalNode{value: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("式の位置 %d の数値 %q が不正です", t.pos, t.text)
+		}
+		return &literalNode{value: f}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		return &variableNode{name: t.text}, nil
+	case tokEOF:
+		return nil, fmt.Errorf("式が途中で終わっています")
+	}
+	return nil, fmt.Errorf("式の位置 %d に不正なトークン %q があります", t.pos, t.text)
+}
+
+type exprNode interface {
+	eval(vars *Variables) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(*Variables) (interface{}, error) {
+	return n.value, nil
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(vars *Variables) (interface{}, error) {
+	value, _ := LookupVariable(vars, n.name)
+	return value, nil
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(vars *Variables) (interface{}, error) {
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(vars *Variables) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" && !truthy(left) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(left) {
+		return true, nil
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+	re          *regexp.Regexp
+}
+
+func (n *compareNode) eval(vars *Variables) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "contains":
+		if list, ok := toList(left); ok {
+			for _, item := range list {
+				if valuesEqual(item, right) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(toString(left), toString(right)), nil
+	case "startsWith":
+		return strings.HasPrefix(toString(left), toString(right)), nil
+	case "endsWith":
+		return strings.HasSuffix(toString(left), toString(right)), nil
+	case "matches":
+		re := n.re
+		if re == nil {
+			re, err = regexp.Compile(toString(right))
+			if err != nil {
+				return nil, fmt.Errorf("正規表現 %q が不正です: %w", toString(right), err)
+			}
+		}
+		return re.MatchString(toString(left)), nil
+	}
+
+	// 大小比較は両辺が数値として解釈できる場合は数値で、それ以外は文字列で比較する
+	var cmp int
+	lf, lok := toNumber(left)
+	rf, rok := toNumber(right)
+	if lok && rok {
+		switch {
+		case lf < rf:
+			cmp = -1
+		case lf > rf:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(toString(left), toString(right))
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("未対応の演算子 %s です", n.op)
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	if f, ok := toNumber(value); ok {
+		return f != 0
+	}
+	// []string など他の型のスライスやマップも要素の有無で判定する
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+// toList スライスを []interface{} に変換します。スライス以外の場合は false を返します
+// トリガーのロールのように []string で格納された変数もリストとして扱えるようにします
+func toList(value interface{}) ([]interface{}, bool) {
+	if list, ok := value.([]interface{}); ok {
+		return list, true
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+func valuesEqual(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	lf, lok := toNumber(left)
+	rf, rok := toNumber(right)
+	if lok && rok {
+		return lf == rf
+	}
+	if lb, ok := left.(bool); ok {
+		return lb == truthy(right)
+	}
+	if rb, ok := right.(bool); ok {
+		return rb == truthy(left)
+	}
+	if reflect.TypeOf(left).Comparable() && reflect.TypeOf(right).Comparable() && left == right {
+		return true
+	}
+	return toString(left) == toString(right)
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
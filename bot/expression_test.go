@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func testVariables() *Variables {
+	vars := NewVariables()
+	vars.Set("trigger.content", "Hello, world")
+	vars.Set("trigger.authorName", "alice")
+	vars.Set("count", 3)
+	vars.Set("ratio", 0.5)
+	vars.Set("score", "42")
+	vars.Set("enabled", true)
+	vars.Set("empty", "")
+	vars.Set("tags", []interface{}{"bug", "urgent"})
+	vars.Set("trigger.roles", []string{"admin", "member"})
+	vars.Set("noRoles", []string{})
+	vars.Set("ids", []int{1, 2})
+	vars.Set("dify.outputs", map[string]interface{}{"status": "ok", "detail": map[string]interface{}{"code": 200.0}})
+	return vars
+}
+
+func TestEvalCondition(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		// 比較
+		{`trigger.authorName == "alice"`, true},
+		{`trigger.authorName != 'alice'`, false},
+		{`count == 3`, true},
+		{`count > 2`, true},
+		{`count >= 4`, false},
+		{`ratio < 1`, true},
+		{`score <= 42`, true},
+		{`score > 100`, false},
+		{`count > -1`, true},
+		{`"b" > "a"`, true},
+		{`enabled == true`, true},
+		{`missing == null`, true},
+		{`missing == ""`, false},
+
+		// 文字列演算
+		{`trigger.content contains "world"`, true},
+		{`trigger.content startsWith "Hello"`, true},
+		{`trigger.content endsWith "!"`, false},
+		{`trigger.content matches "^H.*d$"`, true},
+		{`tags contains "urgent"`, true},
+		{`tags contains "wontfix"`, false},
+		{`trigger.roles contains "admin"`, true},
+		{`trigger.roles contains "adm"`, false},
+		{`ids contains 2`, true},
+		{`ids contains "2"`, true},
+
+		// 真偽値として評価
+		{`enabled`, true},
+		{`empty`, false},
+		{`missing`, false},
+		{`tags`, true},
+		{`trigger.roles`, true},
+		{`noRoles`, false},
+		{`!noRoles`, true},
+		{`!enabled`, false},
+		{`!!count`, true},
+
+		// 論理演算と優先順位
+		{`enabled && count == 3`, true},
+		{`enabled && count == 4`, false},
+		{`count == 4 || trigger.authorName == "alice"`, true},
+		{`false || true && false`, false},
+		{`(false || true) && !false`, true},
+		{`!(count > 2 && empty)`, true},
+
+		// マップを辿る変数参照
+		{`dify.outputs.status == "ok"`, true},
+		{`dify.outputs.detail.code == 200`, true},
+		{`dify.outputs.nope == null`, true},
+	}
+	vars := testVariables()
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := EvalCondition(tt.source, vars)
+			if err != nil {
+				t.Fatalf("EvalCondition(%q): %v", tt.source, err)
+			}
+			if got != tt.want {
+				t.Errorf("EvalCondition(%q) = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{``, "途中で終わっています"},
+		{`count ==`, "途中で終わっています"},
+		{`"unterminated`, "閉じられていません"},
+		{`(count > 1`, "括弧が閉じられていません"},
+		{`count > 1)`, "不正なトークン"},
+		{`count # 1`, "不正な文字"},
+		{`a b`, "不正なトークン"},
+		{`name matches "("`, "正規表現"},
+		{`name matches 1`, "右辺は文字列"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := ParseExpression(tt.source)
+			if err == nil {
+				t.Fatalf("ParseExpression(%q) succeeded, want error", tt.source)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseExpression(%q) error = %q, want it to contain %q", tt.source, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionMatchesDynamicPattern(t *testing.T) {
+	vars := testVariables()
+	vars.Set("pattern", "[")
+	if _, err := EvalCondition(`trigger.content matches pattern`, vars); err == nil {
+		t.Error("invalid pattern from a variable should fail at evaluation")
+	}
+
+	vars.Set("pattern", "world$")
+	got, err := EvalCondition(`trigger.content matches pattern`, vars)
+	if err != nil || !got {
+		t.Errorf("EvalCondition = %v, %v, want true", got, err)
+	}
+}
+
+func TestExpressionEval(t *testing.T) {
+	expr, err := ParseExpression(`dify.outputs.status`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.String() != "dify.outputs.status" {
+		t.Errorf("String() = %q", expr.String())
+	}
+	value, err := expr.Eval(testVariables())
+	if err != nil || value != "ok" {
+		t.Errorf("Eval = %v, %v, want ok", value, err)
+	}
+}
+
+func TestLookupVariable(t *testing.T) {
+	vars := testVariables()
+	vars.Set("dify.outputs.status", "overridden")
+
+	tests := []struct {
+		name  string
+		want  interface{}
+		found bool
+	}{
+		{"trigger.authorName", "alice", true},
+		// 完全一致する変数が優先される
+		{"dify.outputs.status", "overridden", true},
+		{"dify.outputs.detail.code", 200.0, true},
+		{"dify.outputs.detail.nope", nil, false},
+		{"trigger.authorName.length", nil, false},
+		{"nope", nil, false},
+	}
+	for _, tt := range tests {
+		got, found := LookupVariable(vars, tt.name)
+		if found != tt.found || got != tt.want {
+			t.Errorf("LookupVariable(%q) = %v, %v, want %v, %v", tt.name, got, found, tt.want, tt.found)
+		}
+	}
+}
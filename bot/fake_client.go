@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DiscordAction FakeClientが記録したDiscordへの操作
+type DiscordAction struct {
+	Type      string                    `json:"type"`
+	ChannelID string                    `json:"channelId"`
+	MessageID string                    `json:"messageId,omitempty"`
+	Content   string                    `json:"content,omitempty"`
+	Embeds    []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+	Emoji     string                    `json:"emoji,omitempty"`
+	Name      string                    `json:"name,omitempty"`
+	ThreadID  string                    `json:"threadId,omitempty"`
+	ReplyTo   string                    `json:"replyTo,omitempty"`
+	At        time.Time                 `json:"at"`
+}
+
+var _ DiscordClient = (*FakeClient)(nil)
+
+// FakeClient ネットワークに接続せず、操作をメモリ上に記録するDiscordClient
+// フローのシミュレーションやオフラインでの動作確認に使用します
+type FakeClient struct {
+	botUserID string
+
+	mu       sync.Mutex
+	nextID   int
+	actions  []DiscordAction
+	messages map[string]*discordgo.Message
+	users    map[string]*discordgo.User
+	channels map[string]*discordgo.Channel
+}
+
+// NewFakeClient 指定したボットIDとして振る舞うFakeClientを作成
+func NewFakeClient(botUserID string) *FakeClient {
+	return &FakeClient{
+		botUserID: botUserID,
+		messages:  make(map[string]*discordgo.Message),
+		users:     make(map[string]*discordgo.User),
+		channels:  make(map[string]*discordgo.Channel),
+	}
+}
+
+// AddChannel Channel で返すチャンネルを登録します
+// 登録されていないチャンネルはテキストチャンネルとして扱います
+func (c *FakeClient) AddChannel(channel *discordgo.Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.channels[channel.ID] = channel
+}
+
+// AddUser User で返すユーザーを登録します
+func (c *FakeClient) AddUser(user *discordgo.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.users[user.ID] = user
+}
+
+// AddMessage Message で返すメッセージを登録します
+func (c *FakeClient) AddMessage(message *discordgo.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages[message.ID] = message
+}
+
+// Actions 記録された操作を順番に返します
+func (c *FakeClient) Actions() []DiscordAction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	actions := make([]DiscordAction, len(c.actions))
+	copy(actions, c.actions)
+	return actions
+}
+
+func (c *FakeClient) BotUserID() string {
+	return c.botUserID
+}
+
+func (c *FakeClient) SendMessage(channelID, content string) (*discordgo.Message, error) {
+	return c.SendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (c *FakeClient) SendEmbeds(channelID string, embeds ...*discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return c.SendComplex(channelID, &discordgo.MessageSend{Embeds: embeds})
+}
+
+func (c *FakeClient) SendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	message := &discordgo.Message{
+		ID:        c.newID("message"),
+		ChannelID: channelID,
+		Content:   data.Content,
+		Embeds:    data.Embeds,
+		Timestamp: time.Now(),
+		Author:    &discordgo.User{ID: c.botUserID, Bot: true},
+	}
+	c.messages[message.ID] = message
+	replyTo := ""
+	if data.Reference != nil {
+		replyTo = data.Reference.MessageID
+		message.MessageReference = data.Reference
+	}
+	c.record(DiscordAction{
+		Type:      "sendMessage",
+		ChannelID: channelID,
+		MessageID: message.ID,
+		Content:   data.Content,
+		Embeds:    data.Embeds,
+		ReplyTo:   replyTo,
+	})
+	return message, nil
+}
+
+func (c *FakeClient) EditMessage(channelID, messageID, content string) (*discordgo.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	message, ok := c.messages[messageID]
+	if !ok || message.ChannelID != channelID {
+		return nil, fmt.Errorf("message %s not found", messageID)
+	}
+	message.Content = content
+	c.record(DiscordAction{Type: "editMessage", ChannelID: channelID, MessageID: messageID, Content: content})
+	return message, nil
+}
+
+func (c *FakeClient) Typing(channelID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(DiscordAction{Type: "typing", ChannelID: channelID})
+	return nil
+}
+
+func (c *FakeClient) AddReaction(channelID, messageID, emoji string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(DiscordAction{Type: "addReaction", ChannelID: channelID, MessageID: messageID, Emoji: emoji})
+	return nil
+}
+
+func (c *FakeClient) Message(channelID, messageID string) (*discordgo.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	message, ok := c.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("message %s not found", messageID)
+	}
+	return message, nil
+}
+
+func (c *FakeClient) User(userID string) (*discordgo.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	user, ok := c.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user %s not found", userID)
+	}
+	return user, nil
+}
+
+func (c *FakeClient) Channel(channelID string) (*discordgo.Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if channel, ok := c.channels[channelID]; ok {
+		return channel, nil
+	}
+	return &discordgo.Channel{ID: channelID, Type: discordgo.ChannelTypeGuildText}, nil
+}
+
+func (c *FakeClient) CreateThread(channelID, messageID, name string) (*discordgo.Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	thread := &discordgo.Channel{
+		ID:       c.newID("thread"),
+		ParentID: channelID,
+		Name:     name,
+		Type:     discordgo.ChannelTypeGuildPublicThread,
+	}
+	c.channels[thread.ID] = thread
+	c.record(DiscordAction{Type: "createThread", ChannelID: channelID, MessageID: messageID, Name: name, ThreadID: thread.ID})
+	return thread, nil
+}
+
+func (c *FakeClient) newID(prefix string) string {
+	c.nextID++
+	return fmt.Sprintf("fake-%s-%d", prefix, c.nextID)
+}
+
+func (c *FakeClient) record(action DiscordAction) {
+	action.At = time.Now()
+	c.actions = append(c.actions, action)
+}
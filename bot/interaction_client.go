@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// InteractionClient スラッシュコマンドを実行したチャンネルへの送信を
+// インタラクションのフォローアップメッセージとして行うDiscordClient
+type InteractionClient struct {
+	DiscordClient
+	session     *discordgo.Session
+	interaction *discordgo.Interaction
+
+	mu        sync.Mutex
+	responded bool
+	// followups フォローアップとして送信したメッセージ
+	followups map[string]bool
+}
+
+// NewInteractionClient 応答を遅延済みのインタラクションに対するクライアントを作成
+func NewInteractionClient(session *discordgo.Session, interaction *discordgo.Interaction) *InteractionClient {
+	return &InteractionClient{
+		DiscordClient: NewSessionClient(session),
+		session:       session,
+		interaction:   interaction,
+		followups:     make(map[string]bool),
+	}
+}
+
+// Responded フォローアップメッセージを1件以上送信したかを返します
+func (c *InteractionClient) Responded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responded
+}
+
+func (c *InteractionClient) SendMessage(channelID, content string) (*discordgo.Message, error) {
+	return c.SendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (c *InteractionClient) SendEmbeds(channelID string, embeds ...*discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return c.SendComplex(channelID, &discordgo.MessageSend{Embeds: embeds})
+}
+
+func (c *InteractionClient) SendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if channelID != c.interaction.ChannelID {
+		return c.DiscordClient.SendComplex(channelID, data)
+	}
+
+	message, err := c.session.FollowupMessageCreate(c.interaction, true, &discordgo.WebhookParams{
+		Content:         data.Content,
+		Embeds:          data.Embeds,
+		Files:           data.Files,
+		Components:      data.Components,
+		AllowedMentions: data.AllowedMentions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.responded = true
+	c.followups[message.ID] = true
+	c.mu.Unlock()
+	return message, nil
+}
+
+func (c *InteractionClient) EditMessage(channelID, messageID, content string) (*discordgo.Message, error) {
+	c.mu.Lock()
+	followup := c.followups[messageID]
+	c.mu.Unlock()
+	if !followup {
+		return c.DiscordClient.EditMessage(channelID, messageID, content)
+	}
+	return c.session.FollowupMessageEdit(c.interaction, messageID, &discordgo.WebhookEdit{Content: &content})
+}
+
+func (c *InteractionClient) Typing(channelID string) error {
+	// 遅延応答中は「考え中」が表示されるため入力中表示は不要
+	if channelID == c.interaction.ChannelID {
+		return nil
+	}
+	return c.DiscordClient.Typing(channelID)
+}
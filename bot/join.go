@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"discord-bot-service/internal/models"
+	"sort"
+)
+
+// NodeTypeJoin 複数の分岐を待ち合わせる合流ノード
+const NodeTypeJoin = "join"
+
+// 合流ノードの待ち合わせ方法
+const (
+	JoinAll = "all"
+	JoinAny = "any"
+)
+
+// JoinNodeConfig join ノードの設定
+type JoinNodeConfig struct {
+	// Mode all はすべての接続元の到着を待ち、any は最初に到着した分岐だけで後続へ進みます
+	// all で到着しなかった接続元（選ばれなかった分岐や後続へ進まなかったノード）は、分岐がすべて終わった時点で待つのをやめ、
+	// 到着した接続元だけで実行します
+	Mode string `json:"mode"`
+	// Variable 接続元ノードの出力をまとめて格納する変数名。空の場合は "join"
+	Variable string `json:"variable"`
+}
+
+// JoinNodeSchema JoinNodeConfig のスキーマ
+var JoinNodeSchema = ObjectSchema(map[string]*Schema{
+	"mode":     {Type: "string", Title: "待ち合わせ", Enum: []interface{}{JoinAll, JoinAny}, Default: JoinAll},
+	"variable": {Type: "string", Title: "出力先の変数名", Default: "join"},
+})
+
+// WithJoin ノードタイプを合流ノードとして登録
+// 合流ノードは接続元の到着状況に応じて一度だけ実行されます
+func WithJoin() NodeOption {
+	return func(r *nodeRegistration) {
+		r.join = true
+	}
+}
+
+// JoinNodeExecutor 到着した接続元ノードの出力をノードIDごとにまとめて変数に格納します
+// 例: join.difyA.answer
+func JoinNodeExecutor(props NodeProps) (NodeResult, error) {
+	var config JoinNodeConfig
+	if err := DecodeNodeConfig(props.Node, &config); err != nil {
+		return NodeResult{}, err
+	}
+	variable := config.Variable
+	if variable == "" {
+		variable = "join"
+	}
+
+	outputs := make(map[string]interface{}, len(props.Sources))
+	for _, source := range props.Sources {
+		result, ok := props.Exec.Result(source)
+		if !ok {
+			continue
+		}
+		output := make(map[string]interface{}, len(result.Output))
+		for key, value := range result.Output {
+			output[key] = value
+		}
+		outputs[source] = output
+	}
+	props.Exec.Variables.Set(variable, outputs)
+
+	return NodeResult{
+		Type:     NodeTypeJoin,
+		Continue: true,
+		Output:   outputs,
+	}, nil
+}
+
+// arrive 合流ノードへの到着を記録し、これまでに到着した接続元とノードを実行すべきかを返します
+func (s *scope) arrive(edges []models.Edge, node models.Node, from string) ([]string, bool) {
+	var config JoinNodeConfig
+	_ = DecodeNodeConfig(node, &config)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.arrivals[node.ID] = append(s.arrivals[node.ID], from)
+	arrived := append([]string(nil), s.arrivals[node.ID]...)
+
+	if config.Mode == JoinAny {
+		return arrived, len(arrived) == 1
+	}
+	for _, source := range incomingSources(edges, node.ID) {
+		if !contains(arrived, source) {
+			return arrived, false
+		}
+	}
+	return arrived, true
+}
+
+// incomingSources ノードへ接続しているノードのIDを重複なく返します
+func incomingSources(edges []models.Edge, nodeID string) []string {
+	var sources []string
+	for _, edge := range edges {
+		if edge.Target == nodeID && !contains(sources, edge.Source) {
+			sources = append(sources, edge.Source)
+		}
+	}
+	return sources
+}
+
+// pendingJoins 接続元の一部だけが到着し、実行されなかった合流ノードのIDを返します
+func (s *scope) pendingJoins() []string {
+	s.mu.Lock()
+	var ids []string
+	for id := range s.arrivals {
+		if !s.visited[id] {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+
+	// 外側のスコープで実行済みの合流ノードは循環とみなして辿らないため対象外
+	pending := ids[:0]
+	for _, id := range ids {
+		visited := false
+		for p := s.parent; p != nil && !visited; p = p.parent {
+			visited = p.isVisited(id)
+		}
+		if !visited {
+			pending = append(pending, id)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// arrivedAt 合流ノードにこれまでに到着した接続元ノードのIDを返します
+func (s *scope) arrivedAt(nodeID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.arrivals[nodeID]...)
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"discord-bot-service/internal/models"
 	"discord-bot-service/internal/service"
 
 	"github.com/bwmarrin/discordgo"
@@ -24,17 +25,41 @@ type BotManager struct {
 	mu           sync.RWMutex
 	ApiURL       string
 	lastId       string
-	flowService  *service.FlowDataService
+	flowService  *service.BotFlowService
+	runService   *service.FlowRunService
 	timeout      time.Duration
+	// runTimeout 1回のフロー実行にかけられる時間の上限
+	runTimeout time.Duration
+
+	flowCacheMu  sync.Mutex
+	flowCache    map[string]*botFlowIndex
+	flowCacheTTL time.Duration
+
+	// commandGuilds ボットごとにスラッシュコマンドを登録したサーバー（グローバルは空文字）
+	// フローから削除されたサーバーのコマンドを消すために使う
+	commandGuildsMu sync.Mutex
+	commandGuilds   map[string]map[string]bool
+}
+
+// botFlowIndex ボットに紐づくフローをトリガーのタイプごとにまとめたキャッシュ
+type botFlowIndex struct {
+	all       []models.FlowData
+	byTrigger map[string][]models.FlowData
+	loadedAt  time.Time
 }
 
-func NewBotManager(flowService *service.FlowDataService, flowExecutor *FlowExecutor, apiURL string) *BotManager {
+func NewBotManager(flowService *service.BotFlowService, runService *service.FlowRunService, flowExecutor *FlowExecutor, apiURL string) *BotManager {
 	return &BotManager{
-		bots:         make(map[string]*discordgo.Session),
-		flowExecutor: flowExecutor,
-		ApiURL:       apiURL,
-		flowService:  flowService,
-		timeout:      30 * time.Second,
+		bots:          make(map[string]*discordgo.Session),
+		flowExecutor:  flowExecutor,
+		ApiURL:        apiURL,
+		flowService:   flowService,
+		runService:    runService,
+		timeout:       30 * time.Second,
+		runTimeout:    3 * time.Minute,
+		flowCache:     make(map[string]*botFlowIndex),
+		flowCacheTTL:  5 * time.Second,
+		commandGuilds: make(map[string]map[string]bool),
 	}
 }
 
@@ -53,14 +78,15 @@ func (bm *BotManager) AddBot(token string) error {
 		return err
 	}
 
-	// メッセージハンドラを設定
-	dg.AddHandler(bm.handleMessage)
+	// イベントハンドラを設定
+	flows := bm.setupSession(dg, user)
 
 	err = dg.Open()
 	if err != nil {
 		println(err)
 		return err
 	}
+	bm.registerSlashCommands(dg, user.ID, flows)
 
 	bm.mu.Lock()
 	bm.bots[user.ID] = dg
@@ -99,12 +125,14 @@ func (bm *BotManager) RestartAllBots() error {
 			return err
 		}
 
-		newDg.AddHandler(bm.handleMessage)
+		bm.invalidateFlowCache(botID)
+		flows := bm.setupSession(newDg, dg.State.User)
 
 		err = newDg.Open()
 		if err != nil {
 			return err
 		}
+		bm.registerSlashCommands(newDg, botID, flows)
 
 		bm.bots[botID] = newDg
 	}
@@ -112,36 +140,306 @@ func (bm *BotManager) RestartAllBots() error {
 	return nil
 }
 
-func (bm *BotManager) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
-	bm.saveToMessageService(s, m)
+// フローのトリガーに合わせてインテントとイベントハンドラを設定
+func (bm *BotManager) setupSession(dg *discordgo.Session, user *discordgo.User) []models.FlowData {
 	ctx, cancel := context.WithTimeout(context.Background(), bm.timeout)
 	defer cancel()
+
+	flows, err := bm.botFlows(ctx, user)
+	if err != nil {
+		log.Printf("Failed to load flows for bot %s: %v", user.Username, err)
+	}
+	dg.Identify.Intents = TriggerIntents(flows)
+
+	dg.AddHandler(bm.handleMessage)
+	dg.AddHandler(bm.handleMessageUpdate)
+	dg.AddHandler(bm.handleReactionAdd)
+	dg.AddHandler(bm.handleMemberJoin)
+	dg.AddHandler(bm.handleThreadCreate)
+	dg.AddHandler(bm.handleInteraction)
+	return flows
+}
+
+func (bm *BotManager) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	client := NewSessionClient(s)
+	bm.saveToMessageService(client, m)
 	if m.Author.ID == s.State.User.ID {
 		return
 	}
 
+	// スレッド内のメッセージは親チャンネルの指定でも反応させる
+	parentID := threadParentID(s.State, m.ChannelID)
+	newTrigger := func(triggerType string) *Trigger {
+		trigger := NewMessageTrigger(triggerType, m.Message)
+		trigger.ParentChannelID = parentID
+		return trigger
+	}
+
+	// DMはサーバーのメッセージとは別のトリガーで扱う
+	if m.GuildID == "" {
+		bm.runFlows(s, newTrigger(TriggerDirectMessage), client)
+	} else {
+		bm.runFlows(s, newTrigger(TriggerMessageCreate), client)
+	}
+	bm.runFlows(s, newTrigger(TriggerKeyword), client)
+
 	// メンションされてない場合
+	// DMでもメンションを含めば従来どおりメンションのフローを実行する
 	if !strings.Contains(m.Content, "<@"+s.State.User.ID+">") {
 		return
 	}
 
-	// ボットIDを使用してフローを取得
-	flowData, err := bm.flowService.GetFlowData(ctx, s.State.User.Username)
+	bm.runFlows(s, newTrigger(TriggerMention), client)
+}
+
+func (bm *BotManager) handleMessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	// 埋め込みの展開などによる更新は投稿者を含まないため無視する
+	if m.Author == nil || m.Author.ID == s.State.User.ID {
+		return
+	}
+	trigger := NewMessageTrigger(TriggerMessageUpdate, m.Message)
+	trigger.ParentChannelID = threadParentID(s.State, m.ChannelID)
+	bm.runFlows(s, trigger, NewSessionClient(s))
+}
+
+func (bm *BotManager) handleReactionAdd(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	if r.UserID == s.State.User.ID {
+		return
+	}
+	// メッセージの取得にはAPI呼び出しが必要なため、反応するフローがある場合のみ取得する
+	trigger := NewReactionTrigger(r)
+	trigger.ParentChannelID = threadParentID(s.State, r.ChannelID)
+	if !bm.hasMatchingFlow(s, trigger) {
+		return
+	}
+	client := NewSessionClient(s)
+	trigger.loadReactionMessage(client)
+	bm.runFlows(s, trigger, client)
+}
+
+func (bm *BotManager) handleMemberJoin(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	if m.User == nil || m.User.ID == s.State.User.ID {
+		return
+	}
+	systemChannelID := ""
+	if guild, err := s.State.Guild(m.GuildID); err == nil {
+		systemChannelID = guild.SystemChannelID
+	}
+	bm.runFlows(s, NewMemberJoinTrigger(m, systemChannelID), NewSessionClient(s))
+}
+
+func (bm *BotManager) handleThreadCreate(s *discordgo.Session, t *discordgo.ThreadCreate) {
+	// 既存スレッドへの参加時にも通知されるため、新規作成のみを対象にする
+	if !t.NewlyCreated {
+		return
+	}
+	bm.runFlows(s, NewThreadCreateTrigger(t), NewSessionClient(s))
+}
+
+// スラッシュコマンドの実行をフローに渡す
+func (bm *BotManager) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	// インタラクションには3秒以内に応答する必要があるため、先に遅延応答を返す
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
 	if err != nil {
-		// フローが見つからない場合のエラーハンドリング
-		println("flow not found")
+		log.Printf("Error responding to interaction: %v", err)
 		return
 	}
-	// フローを実行
-	_, err = bm.flowExecutor.ExecuteFlow(*flowData, m, s)
+
+	client := NewInteractionClient(s, i.Interaction)
+	bm.runFlows(s, NewInteractionTrigger(i.Interaction), client)
+
+	// フローが何も返信しなかった場合は「考え中」の表示を消す
+	if !client.Responded() {
+		if err := s.InteractionResponseDelete(i.Interaction); err != nil {
+			log.Printf("Error deleting interaction response: %v", err)
+		}
+	}
+}
+
+// ボットに紐づくフローを取得
+func (bm *BotManager) botFlows(ctx context.Context, botUser *discordgo.User) ([]models.FlowData, error) {
+	index, err := bm.flowIndex(ctx, botUser)
+	if err != nil {
+		return nil, err
+	}
+	return index.all, nil
+}
+
+// トリガーのタイプに反応するノードを含むフローを取得
+func (bm *BotManager) flowsForTrigger(ctx context.Context, botUser *discordgo.User, triggerType string) ([]models.FlowData, error) {
+	index, err := bm.flowIndex(ctx, botUser)
+	if err != nil {
+		return nil, err
+	}
+	return index.byTrigger[triggerType], nil
+}
+
+// イベントごとにDBへ問い合わせないよう、フローの一覧を短時間キャッシュする
+func (bm *BotManager) flowIndex(ctx context.Context, botUser *discordgo.User) (*botFlowIndex, error) {
+	bm.flowCacheMu.Lock()
+	defer bm.flowCacheMu.Unlock()
+
+	if index, ok := bm.flowCache[botUser.ID]; ok && time.Since(index.loadedAt) < bm.flowCacheTTL {
+		return index, nil
+	}
+
+	flows, err := bm.flowService.GetBotFlows(ctx, botUser.ID)
 	if err != nil {
+		return nil, err
+	}
+
+	index := &botFlowIndex{
+		all:       flows,
+		byTrigger: make(map[string][]models.FlowData),
+		loadedAt:  time.Now(),
+	}
+	for _, flow := range flows {
+		seen := make(map[string]bool)
+		for _, node := range flow.Nodes {
+			if !bm.flowExecutor.IsTriggerType(node.Type) || seen[node.Type] {
+				continue
+			}
+			seen[node.Type] = true
+			index.byTrigger[node.Type] = append(index.byTrigger[node.Type], flow)
+		}
+	}
+	bm.flowCache[botUser.ID] = index
+	return index, nil
+}
+
+func (bm *BotManager) invalidateFlowCache(botID string) {
+	bm.flowCacheMu.Lock()
+	defer bm.flowCacheMu.Unlock()
+	delete(bm.flowCache, botID)
+}
+
+// ReloadFlowBots フローを紐づけているすべてのボットでフローを読み込み直します
+// フローの公開バージョンを切り替えた後に呼び出します
+func (bm *BotManager) ReloadFlowBots(flowKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), bm.timeout)
+	defer cancel()
+	bindings, err := bm.flowService.GetFlowBindings(ctx, flowKey)
+	if err != nil {
+		log.Printf("Failed to find bots for flow %s: %v", flowKey, err)
+		return
+	}
+	for _, binding := range bindings {
+		bm.ReloadFlows(binding.BotID)
+	}
+}
+
+// ReloadFlows ボットに紐づくフローを読み込み直し、スラッシュコマンドを再登録します
+// 必要なインテントが変わった場合は RestartAllBots で再接続する必要があります
+func (bm *BotManager) ReloadFlows(botID string) {
+	bm.mu.RLock()
+	dg, ok := bm.bots[botID]
+	bm.mu.RUnlock()
+	bm.invalidateFlowCache(botID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bm.timeout)
+	defer cancel()
+	flows, err := bm.botFlows(ctx, dg.State.User)
+	if err != nil {
+		log.Printf("Failed to reload flows for bot %s: %v", botID, err)
+		return
+	}
+	bm.registerSlashCommands(dg, botID, flows)
+}
+
+// トリガーに反応するフローを実行し、実行履歴を保存
+func (bm *BotManager) runFlows(s *discordgo.Session, trigger *Trigger, client DiscordClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), bm.timeout)
+	defer cancel()
+
+	// トリガーに反応するフローを取得
+	flows, err := bm.flowsForTrigger(ctx, s.State.User, trigger.Type)
+	if err != nil {
+		// フローが見つからない場合のエラーハンドリング
+		log.Printf("Failed to load flows for bot %s: %v", s.State.User.Username, err)
 		return
 	}
 
+	trigger.fillGuild(s.State)
+	for _, flowData := range flows {
+		if len(bm.flowExecutor.MatchTrigger(flowData, trigger)) == 0 {
+			continue
+		}
+
+		// フローを実行
+		startedAt := time.Now()
+		runCtx, cancelRun := context.WithTimeout(context.Background(), bm.runTimeout)
+		exec, err := bm.flowExecutor.ExecuteFlow(runCtx, flowData, trigger, client)
+		cancelRun()
+		if err != nil {
+			log.Printf("Flow %s failed: %v", flowData.Key, err)
+		}
+
+		bm.recordRun(NewFlowRun(s.State.User.ID, flowData, trigger, exec, startedAt, err))
+	}
+}
+
+// hasMatchingFlow トリガーに反応するフローがあるかを判定します
+func (bm *BotManager) hasMatchingFlow(s *discordgo.Session, trigger *Trigger) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), bm.timeout)
+	defer cancel()
+	flows, err := bm.flowsForTrigger(ctx, s.State.User, trigger.Type)
+	if err != nil {
+		log.Printf("Failed to load flows for bot %s: %v", s.State.User.Username, err)
+		return false
+	}
+	for _, flowData := range flows {
+		if len(bm.flowExecutor.MatchTrigger(flowData, trigger)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// フロー内の slashCommand ノードをDiscordにコマンドとして登録
+func (bm *BotManager) registerSlashCommands(s *discordgo.Session, appID string, flows []models.FlowData) {
+	bm.commandGuildsMu.Lock()
+	defer bm.commandGuildsMu.Unlock()
+
+	registered := make(map[string]bool)
+	for guildID, cmds := range commandsToOverwrite(SlashCommands(flows), bm.commandGuilds[appID]) {
+		if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, cmds); err != nil {
+			log.Printf("Error registering slash commands for bot %s (guild %q): %v", appID, guildID, err)
+			// 次回の登録で改めて上書きする
+			registered[guildID] = true
+			continue
+		}
+		if len(cmds) > 0 {
+			registered[guildID] = true
+		}
+		log.Printf("Registered %d slash command(s) for bot %s (guild %q)", len(cmds), appID, guildID)
+	}
+	bm.commandGuilds[appID] = registered
+}
+
+// 実行履歴を保存
+func (bm *BotManager) recordRun(run *models.FlowRun) {
+	if bm.runService == nil {
+		return
+	}
+	// フロー実行に時間がかかった場合でも保存できるよう、新しいコンテキストを使う
+	ctx, cancel := context.WithTimeout(context.Background(), bm.timeout)
+	defer cancel()
+	if err := bm.runService.RecordRun(ctx, run); err != nil {
+		log.Printf("Error recording flow run: %v", err)
+	}
 }
 
 // メッセージサーバーにメッセージを保存
-func (bm *BotManager) saveToMessageService(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (bm *BotManager) saveToMessageService(client DiscordClient, m *discordgo.MessageCreate) {
 	// 多重保存を防止
 	bm.mu.RLock()
 	if bm.lastId == m.ID {
@@ -151,8 +449,8 @@ func (bm *BotManager) saveToMessageService(s *discordgo.Session, m *discordgo.Me
 	bm.mu.RUnlock()
 
 	// メンションをユーザー名に変更->引用の文字を追加->
-	cleanMessage := convertMentionsToNames(s, m)
-	cleanMessage = addReplyContextToMessage(s, m) + truncateString(cleanMessage, 500)
+	cleanMessage := convertMentionsToNames(client, m)
+	cleanMessage = addReplyContextToMessage(client, m) + truncateString(cleanMessage, 500)
 
 	message := struct {
 		Data struct {
@@ -196,7 +494,7 @@ func (bm *BotManager) saveToMessageService(s *discordgo.Session, m *discordgo.Me
 	}
 }
 
-func convertMentionsToNames(s *discordgo.Session, m *discordgo.MessageCreate) string {
+func convertMentionsToNames(client DiscordClient, m *discordgo.MessageCreate) string {
 	// メンションを検出する正規表現パターン
 	mentionPattern := regexp.MustCompile(`<@!?(\d+)>`)
 
@@ -206,7 +504,7 @@ func convertMentionsToNames(s *discordgo.Session, m *discordgo.MessageCreate) st
 		userID := strings.Trim(mention, "<@!>")
 
 		// ユーザー情報を取得
-		user, err := s.User(userID)
+		user, err := client.User(userID)
 		if err != nil {
 			return mention // エラーが発生した場合は元のメンションを返す
 		}
@@ -218,14 +516,14 @@ func convertMentionsToNames(s *discordgo.Session, m *discordgo.MessageCreate) st
 	return convertedContent
 }
 
-func addReplyContextToMessage(s *discordgo.Session, m *discordgo.MessageCreate) string {
+func addReplyContextToMessage(client DiscordClient, m *discordgo.MessageCreate) string {
 	// メッセージに返信情報がない場合は空文字を返す
 	if m.MessageReference == nil {
 		return ""
 	}
 
 	// 返信元のメッセージを取得
-	referencedMessage, err := s.ChannelMessage(m.MessageReference.ChannelID, m.MessageReference.MessageID)
+	referencedMessage, err := client.Message(m.MessageReference.ChannelID, m.MessageReference.MessageID)
 	if err != nil {
 		fmt.Printf("Error fetching referenced message: %v\n", err)
 		return ""
@@ -235,7 +533,7 @@ func addReplyContextToMessage(s *discordgo.Session, m *discordgo.MessageCreate)
 	referencedContent := truncateString(referencedMessage.Content, 300)
 
 	// 返信元のユーザー名を取得
-	referencedUser, err := s.User(referencedMessage.Author.ID)
+	referencedUser, err := client.User(referencedMessage.Author.ID)
 	if err != nil {
 		fmt.Printf("Error fetching referenced user: %v\n", err)
 		return ""
@@ -0,0 +1,22 @@
+package bot
+
+import (
+	"discord-bot-service/internal/models"
+	"encoding/json"
+	"fmt"
+)
+
+// DecodeNodeConfig ノードの設定値を構造体に変換します
+func DecodeNodeConfig(node models.Node, out interface{}) error {
+	if len(node.Data.Config) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(node.Data.Config)
+	if err != nil {
+		return fmt.Errorf("ノード %s の設定の変換に失敗しました: %w", node.ID, err)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("ノード %s の設定が不正です: %w", node.ID, err)
+	}
+	return nil
+}
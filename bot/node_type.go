@@ -0,0 +1,89 @@
+package bot
+
+import "sort"
+
+// ノードのカテゴリ。エディタのパレットはカテゴリごとにノードをまとめて表示します
+const (
+	CategoryTrigger = "trigger"
+	CategoryFilter  = "filter"
+	CategoryLogic   = "logic"
+	CategoryAI      = "ai"
+	CategoryDiscord = "discord"
+	CategoryOther   = "other"
+)
+
+// NodeHandle ノードの入出力ハンドル
+// ID が空のハンドルは SourceHandle を指定しないエッジに対応します
+type NodeHandle struct {
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"`
+}
+
+// NodeMetadata エディタに表示するためのノードタイプの情報
+type NodeMetadata struct {
+	DisplayName string       `json:"displayName"`
+	Description string       `json:"description,omitempty"`
+	Category    string       `json:"category"`
+	Inputs      []NodeHandle `json:"inputs"`
+	Outputs     []NodeHandle `json:"outputs"`
+	// DynamicOutputs 設定内容に応じて出力ハンドルが増えるノードか（switch など）
+	DynamicOutputs bool `json:"dynamicOutputs,omitempty"`
+}
+
+// WithMetadata ノードタイプの表示名やハンドルを設定
+// 省略した項目は登録内容から補完されます
+func WithMetadata(metadata NodeMetadata) NodeOption {
+	return func(r *nodeRegistration) {
+		r.metadata = metadata
+	}
+}
+
+// NodeType 登録済みノードタイプの情報
+type NodeType struct {
+	Type string `json:"type"`
+	NodeMetadata
+	IsTrigger bool `json:"isTrigger"`
+	// ConfigSchema NodeData.Config のJSON Schema
+	ConfigSchema *Schema `json:"configSchema,omitempty"`
+}
+
+// NodeTypes 登録済みのノードタイプをタイプ名順で返します
+func (fe *FlowExecutor) NodeTypes() []NodeType {
+	types := make([]NodeType, 0, len(fe.nodeExecutors))
+	for nodeType, registration := range fe.nodeExecutors {
+		types = append(types, NodeType{
+			Type:         nodeType,
+			NodeMetadata: registration.describe(nodeType),
+			IsTrigger:    registration.trigger,
+			ConfigSchema: registration.schema,
+		})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// describe 未設定のメタデータを既定値で補完して返します
+func (r *nodeRegistration) describe(nodeType string) NodeMetadata {
+	metadata := r.metadata
+	if metadata.DisplayName == "" {
+		metadata.DisplayName = nodeType
+	}
+	if metadata.Category == "" {
+		metadata.Category = CategoryOther
+		if r.trigger {
+			metadata.Category = CategoryTrigger
+		}
+	}
+	// トリガーノードはフローの起点なので入力を持たない
+	if r.trigger {
+		metadata.Inputs = []NodeHandle{}
+	} else if metadata.Inputs == nil {
+		metadata.Inputs = []NodeHandle{{}}
+	}
+	if metadata.Outputs == nil {
+		metadata.Outputs = []NodeHandle{{}}
+	}
+	// どのノードも失敗時の分岐先を持てる
+	metadata.Outputs = append(append([]NodeHandle(nil), metadata.Outputs...), NodeHandle{ID: HandleError, Label: "エラー"})
+	return metadata
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"discord-bot-service/internal/models"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// 返信方法
+const (
+	// ReplyModeChannel トリガーのチャンネルへ通常のメッセージとして送信します
+	ReplyModeChannel = "channel"
+	// ReplyModeReply トリガーのメッセージへの返信として送信します
+	ReplyModeReply = "reply"
+	// ReplyModeThread 既存のスレッドへ送信します
+	ReplyModeThread = "thread"
+	// ReplyModeNewThread トリガーのメッセージからスレッドを作成して送信します
+	ReplyModeNewThread = "newThread"
+)
+
+// ReplyOptions メッセージを送信するノードに共通の送信先の設定
+type ReplyOptions struct {
+	Mode string `json:"mode"`
+	// MentionAuthor reply の場合に投稿者へ通知するか
+	MentionAuthor bool `json:"mentionAuthor"`
+	// ThreadID thread の場合の送信先（テンプレート）。空の場合はトリガーのメッセージから作成されたスレッド
+	ThreadID string `json:"threadId"`
+	// ThreadName newThread で作成するスレッドの名前（テンプレート）。空の場合は本文の先頭
+	ThreadName string `json:"threadName"`
+}
+
+// ReplySchema ReplyOptions のスキーマ
+var ReplySchema = ObjectSchema(map[string]*Schema{
+	"mode": {
+		Type:    "string",
+		Title:   "送信方法",
+		Enum:    []interface{}{ReplyModeChannel, ReplyModeReply, ReplyModeThread, ReplyModeNewThread},
+		Default: ReplyModeChannel,
+	},
+	"mentionAuthor": {Type: "boolean", Title: "返信時に投稿者へ通知する"},
+	"threadId":      {Type: "string", Title: "送信先のスレッドID", Format: "template"},
+	"threadName":    {Type: "string", Title: "作成するスレッドの名前", Format: "template"},
+})
+
+// maxThreadName スレッド名の上限
+const maxThreadName = 100
+
+// ReplyTarget 解決済みの送信先
+type ReplyTarget struct {
+	ChannelID       string
+	Reference       *discordgo.MessageReference
+	AllowedMentions *discordgo.MessageAllowedMentions
+}
+
+// Apply 返信の参照を送信内容に設定します
+// 長い本文を分割して送信する場合は最初のメッセージにのみ設定してください
+func (t ReplyTarget) Apply(data *discordgo.MessageSend) {
+	data.Reference = t.Reference
+	if t.AllowedMentions != nil {
+		data.AllowedMentions = t.AllowedMentions
+	}
+}
+
+// ResolveReplyTarget 返信方法に従って送信先を決めます
+// newThread の場合はスレッドを作成し、以降の送信で使えるよう reply.threadId 変数に記録します
+// content は作成するスレッドの既定の名前に使用します
+func ResolveReplyTarget(props NodeProps, opts ReplyOptions, content string) (ReplyTarget, error) {
+	trigger := props.Trigger
+	target := ReplyTarget{ChannelID: trigger.ChannelID}
+	// 同じ実行の中で作成済みのスレッドがあれば会話をそこで続ける
+	if threadID := props.Exec.Variables.GetString("reply.threadId"); threadID != "" {
+		target.ChannelID = threadID
+	}
+
+	switch opts.Mode {
+	case "", ReplyModeChannel:
+		return target, nil
+
+	case ReplyModeReply:
+		// メッセージを起点としないトリガー（スラッシュコマンドなど）では通常の送信になる
+		if trigger.Message == nil || target.ChannelID != trigger.ChannelID {
+			return target, nil
+		}
+		target.Reference = trigger.Message.Reference()
+		target.AllowedMentions = &discordgo.MessageAllowedMentions{
+			Parse:       []discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers, discordgo.AllowedMentionTypeRoles},
+			RepliedUser: opts.MentionAuthor,
+		}
+		return target, nil
+
+	case ReplyModeThread:
+		if opts.ThreadID != "" {
+			threadID, err := RenderTemplate(opts.ThreadID, props.Exec.Variables)
+			if err != nil {
+				return target, err
+			}
+			if threadID = strings.TrimSpace(threadID); threadID != "" {
+				target.ChannelID = threadID
+			}
+		} else if trigger.Message != nil && trigger.Message.Thread != nil {
+			target.ChannelID = trigger.Message.Thread.ID
+		}
+		return target, nil
+
+	case ReplyModeNewThread:
+		if target.ChannelID != trigger.ChannelID {
+			return target, nil
+		}
+		// スレッド内のメッセージやメッセージを起点としないトリガーではスレッドを作成できない
+		if trigger.Message == nil || isThread(props.Client, trigger.ChannelID) {
+			return target, nil
+		}
+		name, err := threadName(props, opts, content)
+		if err != nil {
+			return target, err
+		}
+		thread, err := props.Client.CreateThread(trigger.ChannelID, trigger.Message.ID, name)
+		if err != nil {
+			return target, fmt.Errorf("スレッドを作成できません: %w", err)
+		}
+		props.Exec.Variables.Set("reply.threadId", thread.ID)
+		target.ChannelID = thread.ID
+		return target, nil
+	}
+	return target, fmt.Errorf("未対応の送信方法 %q です", opts.Mode)
+}
+
+func isThread(client DiscordClient, channelID string) bool {
+	channel, err := client.Channel(channelID)
+	return err == nil && channel.IsThread()
+}
+
+func threadName(props NodeProps, opts ReplyOptions, content string) (string, error) {
+	name := ""
+	if opts.ThreadName != "" {
+		rendered, err := RenderTemplate(opts.ThreadName, props.Exec.Variables)
+		if err != nil {
+			return "", err
+		}
+		name = rendered
+	}
+	if strings.TrimSpace(name) == "" {
+		name = props.Trigger.Content
+	}
+	if strings.TrimSpace(name) == "" {
+		name = content
+	}
+	// スレッド名は1行で上限がある
+	name = strings.TrimSpace(strings.SplitN(strings.TrimSpace(name), "\n", 2)[0])
+	if name == "" {
+		name = "返信"
+	}
+	return truncateText(maxThreadName, name), nil
+}
+
+// ValidateReplyOptions 返信の設定に含まれるテンプレートを検証します
+func ValidateReplyOptions(field string, opts ReplyOptions) []models.ValidationError {
+	errs := ValidateTemplateField(field+".threadId", opts.ThreadID)
+	return append(errs, ValidateTemplateField(field+".threadName", opts.ThreadName)...)
+}
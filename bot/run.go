@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"errors"
+	"time"
+)
+
+// NewFlowRun 実行コンテキストから永続化用の実行記録を作成します
+func NewFlowRun(botID string, flow models.FlowData, trigger *Trigger, exec *ExecutionContext, startedAt time.Time, runErr error) *models.FlowRun {
+	run := &models.FlowRun{
+		BotID:      botID,
+		FlowKey:    flow.Key,
+		Status:     models.RunStatusSucceeded,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		Nodes:      []models.NodeRun{},
+	}
+	if trigger != nil {
+		run.TriggerType = trigger.Type
+		run.TriggerMessageID = trigger.ID
+		run.GuildID = trigger.GuildID
+		run.ChannelID = trigger.ChannelID
+	}
+	if runErr != nil {
+		run.Status = runStatus(runErr)
+		run.Error = runErr.Error()
+	}
+
+	if exec == nil {
+		return run
+	}
+	run.Nodes = nodeRuns(exec.Trace())
+	return run
+}
+
+// nodeRuns トレースを永続化用の形式に変換します
+func nodeRuns(trace []TraceEntry) []models.NodeRun {
+	nodes := make([]models.NodeRun, 0, len(trace))
+	for _, entry := range trace {
+		node := models.NodeRun{
+			NodeID:     entry.NodeID,
+			Type:       entry.Type,
+			Scope:      entry.Scope,
+			Continue:   entry.Result.Continue,
+			Handle:     entry.Result.Handle,
+			Output:     entry.Result.Output,
+			Error:      entry.Error,
+			StartedAt:  entry.StartedAt,
+			FinishedAt: entry.FinishedAt,
+		}
+		if len(entry.Children) > 0 {
+			node.Children = nodeRuns(entry.Children)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// runStatus エラーの種類から実行の状態を判定します
+func runStatus(err error) string {
+	switch {
+	case errors.Is(err, ErrNodeTimeout), errors.Is(err, context.DeadlineExceeded):
+		return models.RunStatusTimeout
+	case errors.Is(err, context.Canceled):
+		return models.RunStatusCanceled
+	}
+	return models.RunStatusFailed
+}
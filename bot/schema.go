@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"discord-bot-service/internal/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// Schema ノード設定を記述するJSON Schemaのサブセット
+// エディタはこのスキーマをもとに設定フォームを描画します
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	// AdditionalProperties Properties にないキーの値のスキーマ
+	AdditionalProperties *Schema       `json:"additionalProperties,omitempty"`
+	Enum                 []interface{} `json:"enum,omitempty"`
+	Default              interface{}   `json:"default,omitempty"`
+	MinLength            *int          `json:"minLength,omitempty"`
+	MaxLength            *int          `json:"maxLength,omitempty"`
+	Minimum              *float64      `json:"minimum,omitempty"`
+	Maximum              *float64      `json:"maximum,omitempty"`
+	MaxItems             *int          `json:"maxItems,omitempty"`
+	Pattern              string        `json:"pattern,omitempty"`
+	// Format 値の解釈のヒント（"expression"、"regex" など）
+	Format string `json:"format,omitempty"`
+}
+
+// ObjectSchema プロパティを持つオブジェクトのスキーマを作成
+func ObjectSchema(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// StringSchema 文字列のスキーマを作成
+func StringSchema(title string) *Schema {
+	return &Schema{Type: "string", Title: title}
+}
+
+// StringListSchema 文字列の配列のスキーマを作成
+func StringListSchema(title string) *Schema {
+	return &Schema{Type: "array", Title: title, Items: &Schema{Type: "string"}}
+}
+
+// IntPtr スキーマの MaxItems などの上限を指定するためのヘルパー
+func IntPtr(n int) *int {
+	return &n
+}
+
+// WithConfigSchema ノード設定のスキーマを設定
+// フロー保存時に設定がスキーマに従っているか検証されます
+func WithConfigSchema(schema *Schema) NodeOption {
+	return func(r *nodeRegistration) {
+		r.schema = schema
+	}
+}
+
+// ValidateConfig ノードの設定をスキーマに照らして検証します
+func (s *Schema) ValidateConfig(config map[string]interface{}) []models.ValidationError {
+	// BSON由来の型を含む場合があるため、JSONを経由して素の型に揃える
+	var value interface{} = map[string]interface{}{}
+	if len(config) > 0 {
+		raw, err := json.Marshal(config)
+		if err != nil {
+			return []models.ValidationError{{Field: "config", Message: err.Error()}}
+		}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return []models.ValidationError{{Field: "config", Message: err.Error()}}
+		}
+	}
+	return s.validate(value, "config")
+}
+
+func (s *Schema) validate(value interface{}, path string) []models.ValidationError {
+	if value == nil {
+		return nil
+	}
+	fail := func(format string, args ...interface{}) []models.ValidationError {
+		return []models.ValidationError{{Field: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, candidate := range s.Enum {
+			if valuesEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("%v のいずれかを指定してください", s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fail("オブジェクトを指定してください")
+		}
+		var errs []models.ValidationError
+		for _, name := range s.Required {
+			if v, exists := obj[name]; !exists || v == nil || v == "" {
+				errs = append(errs, models.ValidationError{Field: path + "." + name, Message: "必須項目です"})
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, exists := obj[name]; exists {
+				errs = append(errs, s.Properties[name].validate(v, path+"."+name)...)
+			}
+		}
+		if s.AdditionalProperties != nil {
+			keys := make([]string, 0, len(obj))
+			for key := range obj {
+				if _, ok := s.Properties[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				errs = append(errs, s.AdditionalProperties.validate(obj[key], path+"."+key)...)
+			}
+		}
+		return errs
+
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fail("配列を指定してください")
+		}
+		if s.MaxItems != nil && len(list) > *s.MaxItems {
+			return fail("要素は%d個までです", *s.MaxItems)
+		}
+		var errs []models.ValidationError
+		if s.Items != nil {
+			for i, item := range list {
+				errs = append(errs, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+		return errs
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("文字列を指定してください")
+		}
+		length := len([]rune(str))
+		if s.MinLength != nil && length < *s.MinLength {
+			return fail("%d文字以上で指定してください", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fail("%d文字以内で指定してください", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(str) {
+				if s.Description != "" {
+					return fail("形式が正しくありません（%s）", s.Description)
+				}
+				return fail("形式が正しくありません")
+			}
+		}
+		return nil
+
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			return fail("数値を指定してください")
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			return fail("整数を指定してください")
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			return fail("%v以上で指定してください", *s.Minimum)
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			return fail("%v以下で指定してください", *s.Maximum)
+		}
+		return nil
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("真偽値を指定してください")
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"context"
+	"sync"
+)
+
+// scope ノードの実行済み状態を管理する範囲
+// ループ本体は反復ごとに新しいスコープで実行されるため、同じノードを繰り返し実行できます
+// 一方で、同じスコープや外側のスコープで実行済みのノードへ戻るエッジは循環とみなして辿りません
+type scope struct {
+	ctx context.Context
+	// name トレースに記録するスコープの名前（例: "forEach1#0/retry1#2"）。最上位は空
+	name   string
+	parent *scope
+
+	mu      sync.Mutex
+	visited map[string]bool
+	// arrivals 合流ノードごとに到着した接続元ノードのID
+	arrivals map[string][]string
+}
+
+func newScope(ctx context.Context, name string, parent *scope) *scope {
+	return &scope{
+		ctx:      ctx,
+		name:     name,
+		parent:   parent,
+		visited:  make(map[string]bool),
+		arrivals: make(map[string][]string),
+	}
+}
+
+// child ループ本体の1回分を実行するスコープを作成します
+func (s *scope) child(ctx context.Context, name string) *scope {
+	if s.name != "" {
+		name = s.name + "/" + name
+	}
+	return newScope(ctx, name, s)
+}
+
+// visit ノードを訪問済みにし、実行してよいかを返します
+func (s *scope) visit(nodeID string) bool {
+	for p := s.parent; p != nil; p = p.parent {
+		if p.isVisited(nodeID) {
+			return false
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.visited[nodeID] {
+		return false
+	}
+	s.visited[nodeID] = true
+	return true
+}
+
+func (s *scope) isVisited(nodeID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.visited[nodeID]
+}
@@ -0,0 +1,218 @@
+package bot
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// SlashCommandConfig slashCommand トリガーノードの設定
+type SlashCommandConfig struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Options     []SlashCommandOption `json:"options"`
+	// GuildIDs コマンドを登録するサーバー。空の場合はグローバルコマンドとして登録します
+	GuildIDs []string `json:"guildIds"`
+}
+
+// SlashCommandOption スラッシュコマンドの引数
+type SlashCommandOption struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Type        string               `json:"type"`
+	Required    bool                 `json:"required"`
+	Choices     []SlashCommandChoice `json:"choices"`
+}
+
+// SlashCommandChoice 引数の選択肢
+type SlashCommandChoice struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+var slashCommandOptionTypes = map[string]discordgo.ApplicationCommandOptionType{
+	"string":      discordgo.ApplicationCommandOptionString,
+	"integer":     discordgo.ApplicationCommandOptionInteger,
+	"number":      discordgo.ApplicationCommandOptionNumber,
+	"boolean":     discordgo.ApplicationCommandOptionBoolean,
+	"user":        discordgo.ApplicationCommandOptionUser,
+	"channel":     discordgo.ApplicationCommandOptionChannel,
+	"role":        discordgo.ApplicationCommandOptionRole,
+	"mentionable": discordgo.ApplicationCommandOptionMentionable,
+}
+
+var slashCommandNamePattern = regexp.MustCompile(`^[-_\p{Ll}\p{N}]{1,32}$`)
+
+// SlashCommandSchema SlashCommandConfig のスキーマ
+var SlashCommandSchema = ObjectSchema(map[string]*Schema{
+	"name":        slashCommandNameSchema("コマンド名"),
+	"description": slashCommandDescriptionSchema(),
+	"options": {
+		Type:     "array",
+		Title:    "引数",
+		MaxItems: IntPtr(25),
+		Items: ObjectSchema(map[string]*Schema{
+			"name":        slashCommandNameSchema("引数名"),
+			"description": slashCommandDescriptionSchema(),
+			"type":        {Type: "string", Title: "型", Enum: slashCommandOptionTypeNames()},
+			"required":    {Type: "boolean", Title: "必須"},
+			"choices": {
+				Type:     "array",
+				Title:    "選択肢",
+				MaxItems: IntPtr(25),
+				Items: ObjectSchema(map[string]*Schema{
+					"name":  StringSchema("表示名"),
+					"value": {Title: "値"},
+				}, "name", "value"),
+			},
+		}, "name", "description", "type"),
+	},
+	"guildIds": StringListSchema("登録するサーバーID"),
+}, "name", "description")
+
+func slashCommandNameSchema(title string) *Schema {
+	return &Schema{
+		Type:        "string",
+		Title:       title,
+		Description: "1〜32文字の小文字・数字・-・_",
+		Pattern:     slashCommandNamePattern.String(),
+	}
+}
+
+func slashCommandDescriptionSchema() *Schema {
+	return &Schema{Type: "string", Title: "説明", MinLength: IntPtr(1), MaxLength: IntPtr(100)}
+}
+
+func slashCommandOptionTypeNames() []interface{} {
+	names := make([]string, 0, len(slashCommandOptionTypes))
+	for name := range slashCommandOptionTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return values
+}
+
+// ApplicationCommand Discordに登録するコマンド定義に変換します
+func (c SlashCommandConfig) ApplicationCommand() *discordgo.ApplicationCommand {
+	command := &discordgo.ApplicationCommand{
+		Name:        c.Name,
+		Description: c.Description,
+	}
+	for _, opt := range c.Options {
+		option := &discordgo.ApplicationCommandOption{
+			Name:        opt.Name,
+			Description: opt.Description,
+			Type:        slashCommandOptionTypes[opt.Type],
+			Required:    opt.Required,
+		}
+		for _, choice := range opt.Choices {
+			option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  choice.Name,
+				Value: choice.Value,
+			})
+		}
+		command.Options = append(command.Options, option)
+	}
+	return command
+}
+
+// SlashCommandNodeExecutor slashCommand トリガーノードの実行関数
+func SlashCommandNodeExecutor(props NodeProps) (NodeResult, error) {
+	return NodeResult{
+		Type:     TriggerSlashCommand,
+		Continue: true,
+		Output:   props.Trigger.Options,
+	}, nil
+}
+
+// MatchSlashCommand 実行されたコマンド名がノードの設定と一致するかを判定します
+func MatchSlashCommand(node models.Node, trigger *Trigger) bool {
+	var config SlashCommandConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return false
+	}
+	return config.Name == trigger.CommandName
+}
+
+// ValidateSlashCommandNode スキーマでは表せない slashCommand ノードの制約を検証します
+func ValidateSlashCommandNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config SlashCommandConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+
+	var errs []models.ValidationError
+	names := make(map[string]bool)
+	optional := false
+	for i, opt := range config.Options {
+		field := fmt.Sprintf("config.options[%d]", i)
+		if names[opt.Name] {
+			errs = append(errs, models.ValidationError{Field: field + ".name", Message: "引数名が重複しています"})
+		}
+		names[opt.Name] = true
+		if opt.Required && optional {
+			errs = append(errs, models.ValidationError{Field: field + ".required", Message: "必須の引数は任意の引数より前に置く必要があります"})
+		}
+		if !opt.Required {
+			optional = true
+		}
+	}
+	return errs
+}
+
+// SlashCommands フロー内の slashCommand ノードから登録すべきコマンドをサーバーごとに集めます
+// キーが空文字のものはグローバルコマンドです
+func SlashCommands(flows []models.FlowData) map[string][]*discordgo.ApplicationCommand {
+	commands := make(map[string][]*discordgo.ApplicationCommand)
+	seen := make(map[string]bool)
+	for _, flow := range flows {
+		for _, node := range flow.Nodes {
+			if node.Type != TriggerSlashCommand {
+				continue
+			}
+			var config SlashCommandConfig
+			if err := DecodeNodeConfig(node, &config); err != nil || config.Name == "" {
+				continue
+			}
+			guildIDs := config.GuildIDs
+			if len(guildIDs) == 0 {
+				guildIDs = []string{""}
+			}
+			for _, guildID := range guildIDs {
+				// 同じサーバーに同名のコマンドは1つしか登録できない
+				if seen[guildID+"/"+config.Name] {
+					continue
+				}
+				seen[guildID+"/"+config.Name] = true
+				commands[guildID] = append(commands[guildID], config.ApplicationCommand())
+			}
+		}
+	}
+	return commands
+}
+
+// commandsToOverwrite 登録するコマンドに、コマンドがなくなったサーバーの空の一覧を加えます
+// グローバルと前回登録したサーバーは常に上書きし、フローから削除されたコマンドを消します
+func commandsToOverwrite(commands map[string][]*discordgo.ApplicationCommand, registered map[string]bool) map[string][]*discordgo.ApplicationCommand {
+	overwrite := make(map[string][]*discordgo.ApplicationCommand, len(commands)+len(registered)+1)
+	for guildID, cmds := range commands {
+		overwrite[guildID] = cmds
+	}
+	for guildID := range registered {
+		if _, ok := overwrite[guildID]; !ok {
+			overwrite[guildID] = []*discordgo.ApplicationCommand{}
+		}
+	}
+	if _, ok := overwrite[""]; !ok {
+		overwrite[""] = []*discordgo.ApplicationCommand{}
+	}
+	return overwrite
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"discord-bot-service/internal/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func slashCommandNode(id, name string, guildIDs ...string) models.Node {
+	config := map[string]interface{}{"name": name, "description": name + " command"}
+	if len(guildIDs) > 0 {
+		ids := make([]interface{}, len(guildIDs))
+		for i, guildID := range guildIDs {
+			ids[i] = guildID
+		}
+		config["guildIds"] = ids
+	}
+	return testNode(id, TriggerSlashCommand, config)
+}
+
+// commandNames サーバーごとのコマンド名を並べ替えて返します
+func commandNames(commands map[string][]*discordgo.ApplicationCommand) map[string][]string {
+	names := make(map[string][]string, len(commands))
+	for guildID, cmds := range commands {
+		names[guildID] = []string{}
+		for _, cmd := range cmds {
+			names[guildID] = append(names[guildID], cmd.Name)
+		}
+		sort.Strings(names[guildID])
+	}
+	return names
+}
+
+func TestSlashCommands(t *testing.T) {
+	flows := []models.FlowData{
+		{Key: "a", Nodes: []models.Node{
+			slashCommandNode("s1", "ping"),
+			slashCommandNode("s2", "ask", "g1", "g2"),
+			testNode("other", "say", map[string]interface{}{"name": "ignored"}),
+		}},
+		{Key: "b", Nodes: []models.Node{
+			// 同じサーバーの同名コマンドは優先度の高いフローのものだけを登録する
+			slashCommandNode("s1", "ping"),
+			slashCommandNode("s2", "ask", "g2"),
+			slashCommandNode("s3", "ping", "g1"),
+			// 名前のないノードは登録しない
+			slashCommandNode("s4", ""),
+		}},
+	}
+
+	commands := SlashCommands(flows)
+	want := map[string][]string{
+		"":   {"ping"},
+		"g1": {"ask", "ping"},
+		"g2": {"ask"},
+	}
+	if got := commandNames(commands); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+	if commands[""][0].Description != "ping command" {
+		t.Errorf("description = %q, want the first flow's command", commands[""][0].Description)
+	}
+}
+
+func TestCommandsToOverwrite(t *testing.T) {
+	commands := SlashCommands([]models.FlowData{{Nodes: []models.Node{slashCommandNode("s1", "ask", "g1")}}})
+
+	got := commandNames(commandsToOverwrite(commands, map[string]bool{"": true, "g1": true, "g2": true}))
+	want := map[string][]string{
+		// グローバルと、コマンドがなくなったサーバーは空の一覧で上書きする
+		"":   {},
+		"g1": {"ask"},
+		"g2": {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("overwrite = %v, want %v", got, want)
+	}
+
+	if got := commandNames(commandsToOverwrite(nil, nil)); !reflect.DeepEqual(got, map[string][]string{"": {}}) {
+		t.Errorf("no commands: overwrite = %v, want only an empty global list", got)
+	}
+}
+
+func TestValidateSlashCommandNode(t *testing.T) {
+	option := func(name string, required bool) map[string]interface{} {
+		return map[string]interface{}{"name": name, "description": name, "type": "string", "required": required}
+	}
+	tests := []struct {
+		name    string
+		options []interface{}
+		fields  []string
+	}{
+		{"valid", []interface{}{option("a", true), option("b", false)}, nil},
+		{"duplicate name", []interface{}{option("a", true), option("a", false)}, []string{"config.options[1].name"}},
+		{"required after optional", []interface{}{option("a", false), option("b", true)}, []string{"config.options[1].required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := testNode("s1", TriggerSlashCommand, map[string]interface{}{
+				"name":        "ask",
+				"description": "ask",
+				"options":     tt.options,
+			})
+			var fields []string
+			for _, err := range ValidateSlashCommandNode(context.Background(), node) {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("error fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
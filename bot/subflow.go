@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NodeTypeSubflow 別のフローを呼び出すノード
+const NodeTypeSubflow = "subflow"
+
+// FlowLoader subflow ノードが呼び出すフローを取得するインターフェース
+type FlowLoader interface {
+	GetPublishedFlow(ctx context.Context, key string) (*models.FlowData, error)
+}
+
+// ErrSubflowDepth subflow ノードによる呼び出しが深すぎる場合のエラー
+var ErrSubflowDepth = errors.New("サブフローの呼び出しが深すぎます")
+
+// SubflowNodeConfig subflow ノードの設定
+type SubflowNodeConfig struct {
+	// FlowKey 呼び出すフローのキー。公開中のバージョンが実行されます
+	FlowKey string `json:"flowKey"`
+	// Inputs 呼び出し先の変数名と、その値を求める呼び出し元での式
+	Inputs map[string]string `json:"inputs"`
+	// Outputs 呼び出し元の変数名と、値を取り出す呼び出し先の変数名
+	Outputs map[string]string `json:"outputs"`
+}
+
+// SubflowNodeSchema SubflowNodeConfig のスキーマ
+var SubflowNodeSchema = ObjectSchema(map[string]*Schema{
+	"flowKey": StringSchema("呼び出すフロー"),
+	"inputs": {
+		Type:                 "object",
+		Title:                "入力",
+		Description:          "呼び出し先の変数名 → 呼び出し元での式",
+		AdditionalProperties: &Schema{Type: "string", Format: "expression"},
+	},
+	"outputs": {
+		Type:                 "object",
+		Title:                "出力",
+		Description:          "呼び出し元の変数名 → 呼び出し先の変数名",
+		AdditionalProperties: &Schema{Type: "string"},
+	},
+}, "flowKey")
+
+// SubflowNodeExecutor 別のフローを同じトリガーで実行し、指定した変数を呼び出し元へ返します
+// 呼び出し先のフローは subflowStart ノードから実行されます
+func SubflowNodeExecutor(props NodeProps) (NodeResult, error) {
+	var config SubflowNodeConfig
+	if err := DecodeNodeConfig(props.Node, &config); err != nil {
+		return NodeResult{}, err
+	}
+	parent := props.run
+	if parent == nil {
+		return NodeResult{}, errors.New("subflow ノードはフローの実行中にのみ使用できます")
+	}
+	fe := parent.executor
+	if len(parent.stack) >= fe.MaxSubflowDepth {
+		return NodeResult{}, fmt.Errorf("%w: %s", ErrSubflowDepth, strings.Join(parent.stack, " → "))
+	}
+	if fe.Flows == nil {
+		return NodeResult{}, errors.New("サブフローの取得元が設定されていません")
+	}
+
+	flow, err := fe.Flows.GetPublishedFlow(props.Context, config.FlowKey)
+	if err != nil {
+		return NodeResult{}, fmt.Errorf("サブフロー %s を取得できません: %w", config.FlowKey, err)
+	}
+
+	// 呼び出し先は呼び出し元の変数を共有せず、トリガーの情報と入力だけを受け取る
+	exec := NewExecutionContext(props.Context)
+	setTriggerVariables(exec.Variables, parent.trigger)
+	for name, source := range config.Inputs {
+		expr, err := ParseExpression(source)
+		if err != nil {
+			return NodeResult{}, fmt.Errorf("入力 %s: %w", name, err)
+		}
+		value, err := expr.Eval(props.Exec.Variables)
+		if err != nil {
+			return NodeResult{}, fmt.Errorf("入力 %s: %w", name, err)
+		}
+		exec.Variables.Set(name, value)
+	}
+
+	run := fe.newRun(*flow, exec, parent.trigger, parent.client)
+	run.stack = append(append([]string(nil), parent.stack...), flow.Key)
+	// 呼び出し元も並行実行の場合は枠を共有し、フロー全体で並行数の上限を超えないようにする
+	if run.slots != nil && parent.slots != nil {
+		run.slots = parent.slots
+	}
+
+	var startNodes []models.Node
+	if parent.trigger != nil {
+		trigger := *parent.trigger
+		trigger.Type = TriggerSubflow
+		startNodes = fe.MatchTrigger(*flow, &trigger)
+	}
+	if len(startNodes) == 0 {
+		return NodeResult{}, fmt.Errorf("サブフロー %s に %s ノードがありません", flow.Key, TriggerSubflow)
+	}
+
+	err = fe.executeScope(run, newScope(props.Context, "", nil), "", startNodes)
+	props.nested.set(exec.Trace())
+	if err != nil {
+		return NodeResult{Type: NodeTypeSubflow}, fmt.Errorf("サブフロー %s: %w", flow.Key, err)
+	}
+
+	output := make(map[string]interface{}, len(config.Outputs))
+	for name, source := range config.Outputs {
+		if value, ok := LookupVariable(exec.Variables, source); ok {
+			props.Exec.Variables.Set(name, value)
+			output[name] = value
+		}
+	}
+	return NodeResult{
+		Type:     NodeTypeSubflow,
+		Continue: true,
+		Output:   output,
+	}, nil
+}
+
+// ValidateSubflowNode 入力の式を検証します
+func ValidateSubflowNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config SubflowNodeConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+	var errs []models.ValidationError
+	for name, source := range config.Inputs {
+		errs = append(errs, ValidateExpressionField("config.inputs."+name, source)...)
+	}
+	return errs
+}
+
+// nestedTrace ノードの中で実行されたフローの記録
+// タイムアウト後も実行関数から書き込まれる可能性があるためロックで保護する
+type nestedTrace struct {
+	mu    sync.Mutex
+	trace []TraceEntry
+}
+
+func (n *nestedTrace) set(trace []TraceEntry) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.trace = trace
+}
+
+func (n *nestedTrace) entries() []TraceEntry {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.trace
+}
@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"discord-bot-service/internal/models"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// 返信文などのテンプレートでは text/template の構文を使用します
+//
+//	{{.trigger.authorName}} さん、{{.dify.answer | truncate 1500}}
+//	{{if .options.verbose}}詳細: {{json .join}}{{end}}
+//	ロール: {{join ", " .trigger.roles}}
+//
+// 変数はドット区切りの名前が入れ子のマップとして参照でき、
+// 入れ子で参照できない名前は {{var "name"}} で取得します
+// 存在しない変数や値が nil の変数は空文字として出力します
+
+// templateFuncs テンプレートから使用できる関数
+// var は描画時に実行中の変数ストアを参照する関数へ置き換えます
+var templateFuncs = template.FuncMap{
+	"var":      func(string) interface{} { return nil },
+	"truncate": truncateText,
+	"upper":    func(v interface{}) string { return strings.ToUpper(toString(v)) },
+	"lower":    func(v interface{}) string { return strings.ToLower(toString(v)) },
+	"trim":     func(v interface{}) string { return strings.TrimSpace(toString(v)) },
+	"json":     toJSON,
+	"default":  defaultValue,
+	"mention":  func(id interface{}) string { return "<@" + toString(id) + ">" },
+	"channel":  func(id interface{}) string { return "<#" + toString(id) + ">" },
+	"join":     joinValues,
+	"orEmpty":  emptyIfNil,
+}
+
+// ParseTemplate テンプレートを解析します
+func ParseTemplate(source string) (*template.Template, error) {
+	tmpl, err := template.New("template").Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		emptyMissingValues(t.Tree.Root)
+	}
+	return tmpl, nil
+}
+
+// emptyMissingValues 値を出力するすべてのアクションの末尾に orEmpty を追加します
+// text/template は値のない変数を "<no value>" と出力するため、空文字に置き換えます
+func emptyMissingValues(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			emptyMissingValues(child)
+		}
+	case *parse.ActionNode:
+		// {{$x := ...}} のような変数の宣言は何も出力しない
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier("orEmpty").SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		emptyMissingValues(n.List)
+		emptyMissingValues(n.ElseList)
+	case *parse.RangeNode:
+		emptyMissingValues(n.List)
+		emptyMissingValues(n.ElseList)
+	case *parse.WithNode:
+		emptyMissingValues(n.List)
+		emptyMissingValues(n.ElseList)
+	}
+}
+
+// emptyIfNil 値が nil の場合に空文字を返します
+func emptyIfNil(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+// RenderTemplate 実行中の変数を使ってテンプレートを描画します
+func RenderTemplate(source string, vars *Variables) (string, error) {
+	tmpl, err := ParseTemplate(source)
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"var": func(name string) interface{} {
+			value, _ := LookupVariable(vars, name)
+			return value
+		},
+	})
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nestVariables(vars.Snapshot())); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ValidateTemplateField 設定中のテンプレートを検証するためのヘルパー
+func ValidateTemplateField(field, source string) []models.ValidationError {
+	if _, err := ParseTemplate(source); err != nil {
+		return []models.ValidationError{{Field: field, Message: err.Error()}}
+	}
+	return nil
+}
+
+// nestVariables "dify.answer" のようなドット区切りの変数名を入れ子のマップに変換します
+// 途中の名前がマップ以外の値として既に存在する場合、その変数は var 関数でのみ参照できます
+func nestVariables(values map[string]interface{}) map[string]interface{} {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	// "dify" が "dify.answer" より先に設定されるよう名前順に処理する
+	sort.Strings(names)
+
+	root := make(map[string]interface{})
+	for _, name := range names {
+		parts := strings.Split(name, ".")
+		node := root
+		for _, part := range parts[:len(parts)-1] {
+			child, exists := node[part]
+			if !exists {
+				child = make(map[string]interface{})
+			}
+			m, isMap := child.(map[string]interface{})
+			if !isMap {
+				node = nil
+				break
+			}
+			if exists {
+				// 変数ストアの値を書き換えないようコピーしてから追加する
+				copied := make(map[string]interface{}, len(m))
+				for k, v := range m {
+					copied[k] = v
+				}
+				m = copied
+			}
+			node[part] = m
+			node = m
+		}
+		if node != nil {
+			node[parts[len(parts)-1]] = values[name]
+		}
+	}
+	return root
+}
+
+// truncateText 指定した文字数を超える部分を "…" に置き換えます
+func truncateText(length int, v interface{}) string {
+	runes := []rune(toString(v))
+	if length < 0 || len(runes) <= length {
+		return string(runes)
+	}
+	if length == 0 {
+		return ""
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("json: %w", err)
+	}
+	return string(b), nil
+}
+
+// joinValues 配列の要素を区切り文字で連結します。配列以外はそのまま文字列にします
+func joinValues(sep string, v interface{}) string {
+	switch list := v.(type) {
+	case []string:
+		return strings.Join(list, sep)
+	case []interface{}:
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, sep)
+	}
+	return toString(v)
+}
+
+// defaultValue 値が空の場合に既定値を返します
+func defaultValue(fallback, v interface{}) interface{} {
+	if v == nil || toString(v) == "" {
+		return fallback
+	}
+	return v
+}
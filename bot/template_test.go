@@ -0,0 +1,66 @@
+package bot
+
+import "testing"
+
+func TestRenderTemplate(t *testing.T) {
+	vars := NewVariables()
+	vars.Set("trigger.authorName", "alice")
+	vars.Set("trigger.roles", []string{"admin", "member"})
+	vars.Set("dify.answer", "こんにちは、世界")
+	vars.Set("count", 3)
+	vars.Set("empty", nil)
+	vars.Set("items", []interface{}{"a", nil, "c"})
+	vars.Set("user", "bob")
+	// "user" が文字列のため入れ子では参照できない
+	vars.Set("user.id", "42")
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"variable", "{{.trigger.authorName}} さん", "alice さん"},
+		{"missing variable", "[{{.nope}}]", "[]"},
+		{"missing nested variable", "[{{.nope.deeper}}]", "[]"},
+		{"missing under existing map", "[{{.dify.nope}}]", "[]"},
+		{"nil value", "[{{.empty}}]", "[]"},
+		{"missing var function", `[{{var "nope"}}]`, "[]"},
+		{"var function", `{{var "user.id"}}`, "42"},
+		{"default for missing", `{{default "guest" .nope}}`, "guest"},
+		{"default for present", `{{default "guest" .user}}`, "bob"},
+		{"missing in if", "{{if .nope}}yes{{else}}no{{end}}", "no"},
+		{"missing in range", "{{range .items}}[{{.}}]{{end}}", "[a][][c]"},
+		{"missing in with else", "{{with .nope}}{{.}}{{else}}[{{.nope}}]{{end}}", "[]"},
+		{"declaration", "{{$name := .user}}{{$name}}", "bob"},
+		{"truncate", "{{.dify.answer | truncate 5}}", "こんにち…"},
+		{"upper", "{{upper .user}}", "BOB"},
+		{"join", `{{join ", " .trigger.roles}}`, "admin, member"},
+		{"json", "{{json .count}}", "3"},
+		{"mention var", `{{mention (var "user.id")}}`, "<@42>"},
+		{"literal", "no value", "no value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderTemplate(tt.source, vars)
+			if err != nil {
+				t.Fatalf("RenderTemplate(%q): %v", tt.source, err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderTemplate(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTemplateField(t *testing.T) {
+	if errs := ValidateTemplateField("content", "{{.trigger.authorName}}"); len(errs) != 0 {
+		t.Errorf("valid template: errs = %+v", errs)
+	}
+	errs := ValidateTemplateField("content", "{{.trigger.authorName")
+	if len(errs) != 1 || errs[0].Field != "content" {
+		t.Errorf("invalid template: errs = %+v, want one error for content", errs)
+	}
+	if errs := ValidateTemplateField("content", "{{unknown .x}}"); len(errs) != 1 {
+		t.Errorf("unknown function: errs = %+v, want one error", errs)
+	}
+}
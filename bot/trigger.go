@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"discord-bot-service/internal/models"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// トリガーノードのタイプ
+const (
+	// TriggerMention ボットへのメンション（従来の start ノード）
+	TriggerMention = "start"
+	// TriggerSlashCommand スラッシュコマンドの実行
+	TriggerSlashCommand = "slashCommand"
+	// TriggerMessageCreate チャンネルへのメッセージ投稿（メンション不要）
+	TriggerMessageCreate = "messageCreate"
+	// TriggerDirectMessage ボットへのDM
+	TriggerDirectMessage = "directMessage"
+	// TriggerKeyword キーワードまたは正規表現に一致するメッセージ
+	TriggerKeyword = "keyword"
+	// TriggerReactionAdd メッセージへのリアクション追加
+	TriggerReactionAdd = "reactionAdd"
+	// TriggerMemberJoin サーバーへのメンバー参加
+	TriggerMemberJoin = "memberJoin"
+	// TriggerThreadCreate スレッドの作成
+	TriggerThreadCreate = "threadCreate"
+	// TriggerMessageUpdate メッセージの編集
+	TriggerMessageUpdate = "messageUpdate"
+	// TriggerSubflow subflow ノードから呼び出されたときの開始点
+	TriggerSubflow = "subflowStart"
+)
+
+// Trigger フロー実行のきっかけとなったイベント
+type Trigger struct {
+	// Type 反応させるトリガーノードのタイプ
+	Type    string
+	ID      string
+	GuildID string
+	// GuildName サーバー名（キャッシュから取得できた場合のみ）
+	GuildName string
+	ChannelID string
+	// ParentChannelID スレッド内のイベントの場合の親チャンネル
+	ParentChannelID string
+	Author          *discordgo.User
+	// Roles 投稿者のサーバーでのロール名（キャッシュから取得できた場合のみ）
+	Roles   []string
+	Content string
+	// Emoji リアクションの場合の絵文字（カスタム絵文字は name:id 形式）
+	Emoji string
+	// Message メッセージを起点とするトリガーの場合の元メッセージ
+	Message *discordgo.Message
+	// Interaction スラッシュコマンドの場合のインタラクション
+	Interaction *discordgo.Interaction
+	CommandName string
+	Options     map[string]interface{}
+}
+
+// TriggerMatcher トリガーノードがイベントに反応するかを判定する関数型
+type TriggerMatcher func(node models.Node, trigger *Trigger) bool
+
+// WithTriggerMatcher ノードタイプをトリガーノードとして登録し、反応条件を設定
+// matcher が nil の場合はタイプが一致するだけで反応します
+func WithTriggerMatcher(matcher TriggerMatcher) NodeOption {
+	return func(r *nodeRegistration) {
+		r.trigger = true
+		r.matcher = matcher
+	}
+}
+
+// NewMessageTrigger メッセージを起点とするトリガーを作成
+func NewMessageTrigger(triggerType string, m *discordgo.Message) *Trigger {
+	return &Trigger{
+		Type:      triggerType,
+		ID:        m.ID,
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		Author:    m.Author,
+		Content:   m.Content,
+		Message:   m,
+	}
+}
+
+// threadParentID スレッド内のチャンネルの場合に親チャンネルのIDを返します
+// Gatewayのキャッシュだけを参照し、キャッシュにないチャンネルはスレッドでないものとして扱います
+func threadParentID(state *discordgo.State, channelID string) string {
+	channel, err := state.Channel(channelID)
+	if err != nil || !channel.IsThread() {
+		return ""
+	}
+	return channel.ParentID
+}
+
+// NewInteractionTrigger スラッシュコマンドのインタラクションからトリガーを作成
+// 最初の文字列オプションの値をトリガーの本文として扱います
+func NewInteractionTrigger(i *discordgo.Interaction) *Trigger {
+	data := i.ApplicationCommandData()
+	trigger := &Trigger{
+		Type:        TriggerSlashCommand,
+		ID:          i.ID,
+		GuildID:     i.GuildID,
+		ChannelID:   i.ChannelID,
+		Interaction: i,
+		CommandName: data.Name,
+		Options:     make(map[string]interface{}, len(data.Options)),
+	}
+	if i.Member != nil {
+		trigger.Author = i.Member.User
+	} else {
+		trigger.Author = i.User
+	}
+	for _, opt := range data.Options {
+		trigger.Options[opt.Name] = opt.Value
+		if s, ok := opt.Value.(string); ok && opt.Type == discordgo.ApplicationCommandOptionString && trigger.Content == "" {
+			trigger.Content = s
+		}
+	}
+	return trigger
+}
+
+// fillGuild セッションのキャッシュからサーバー名と投稿者のロール名を設定します
+func (t *Trigger) fillGuild(state *discordgo.State) {
+	if t.GuildID == "" || state == nil {
+		return
+	}
+	guild, err := state.Guild(t.GuildID)
+	if err != nil {
+		return
+	}
+	t.GuildName = guild.Name
+
+	var member *discordgo.Member
+	switch {
+	case t.Message != nil && t.Message.Member != nil:
+		member = t.Message.Member
+	case t.Interaction != nil && t.Interaction.Member != nil:
+		member = t.Interaction.Member
+	default:
+		return
+	}
+	t.Roles = nil
+	for _, roleID := range member.Roles {
+		if role, err := state.Role(t.GuildID, roleID); err == nil {
+			t.Roles = append(t.Roles, role.Name)
+		}
+	}
+}
+
+// setTriggerVariables はトリガーの情報を変数に設定します
+func setTriggerVariables(vars *Variables, trigger *Trigger) {
+	if trigger == nil {
+		return
+	}
+	vars.Set("trigger.type", trigger.Type)
+	vars.Set("trigger.id", trigger.ID)
+	vars.Set("trigger.guildId", trigger.GuildID)
+	if trigger.GuildName != "" {
+		vars.Set("trigger.guildName", trigger.GuildName)
+	}
+	vars.Set("trigger.channelId", trigger.ChannelID)
+	vars.Set("trigger.content", trigger.Content)
+	if trigger.ParentChannelID != "" {
+		vars.Set("trigger.parentChannelId", trigger.ParentChannelID)
+	}
+	if trigger.Emoji != "" {
+		vars.Set("trigger.emoji", trigger.Emoji)
+	}
+	if trigger.Message != nil {
+		vars.Set("trigger.messageId", trigger.Message.ID)
+		// 返信として投稿されたメッセージの場合は返信先
+		if ref := trigger.Message.ReferencedMessage; ref != nil {
+			vars.Set("trigger.reply.messageId", ref.ID)
+			vars.Set("trigger.reply.content", ref.Content)
+			if ref.Author != nil {
+				vars.Set("trigger.reply.authorId", ref.Author.ID)
+				vars.Set("trigger.reply.authorName", ref.Author.Username)
+			}
+		}
+	}
+	if trigger.Author != nil {
+		vars.Set("trigger.authorId", trigger.Author.ID)
+		vars.Set("trigger.authorName", trigger.Author.Username)
+	}
+	if len(trigger.Roles) > 0 {
+		vars.Set("trigger.roles", trigger.Roles)
+	}
+	if trigger.CommandName != "" {
+		vars.Set("trigger.command", trigger.CommandName)
+	}
+	for name, value := range trigger.Options {
+		vars.Set(fmt.Sprintf("options.%s", name), value)
+	}
+}
@@ -0,0 +1,295 @@
+package bot
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// MessageTriggerConfig messageCreate / directMessage / messageUpdate トリガーの設定
+type MessageTriggerConfig struct {
+	// ChannelIDs 反応するチャンネル。空の場合はすべてのチャンネル
+	ChannelIDs []string `json:"channelIds"`
+	// IncludeBots 他のボットの投稿にも反応するか
+	IncludeBots bool `json:"includeBots"`
+}
+
+// KeywordTriggerConfig keyword トリガーの設定
+type KeywordTriggerConfig struct {
+	MessageTriggerConfig
+	// Keywords いずれかを含むメッセージに反応します
+	Keywords []string `json:"keywords"`
+	// Pattern 正規表現に一致するメッセージに反応します
+	Pattern       string `json:"pattern"`
+	CaseSensitive bool   `json:"caseSensitive"`
+}
+
+// ReactionTriggerConfig reactionAdd トリガーの設定
+type ReactionTriggerConfig struct {
+	// Emojis 反応する絵文字。空の場合はすべての絵文字
+	Emojis     []string `json:"emojis"`
+	ChannelIDs []string `json:"channelIds"`
+}
+
+// MemberJoinTriggerConfig memberJoin トリガーの設定
+type MemberJoinTriggerConfig struct {
+	GuildIDs []string `json:"guildIds"`
+}
+
+// ThreadCreateTriggerConfig threadCreate トリガーの設定
+type ThreadCreateTriggerConfig struct {
+	ParentChannelIDs []string `json:"parentChannelIds"`
+}
+
+// MessageTriggerSchema MessageTriggerConfig のスキーマ
+var MessageTriggerSchema = ObjectSchema(map[string]*Schema{
+	"channelIds":  StringListSchema("反応するチャンネルID"),
+	"includeBots": {Type: "boolean", Title: "ボットの投稿にも反応する"},
+})
+
+// KeywordTriggerSchema KeywordTriggerConfig のスキーマ
+var KeywordTriggerSchema = ObjectSchema(map[string]*Schema{
+	"channelIds":    StringListSchema("反応するチャンネルID"),
+	"includeBots":   {Type: "boolean", Title: "ボットの投稿にも反応する"},
+	"keywords":      StringListSchema("キーワード"),
+	"pattern":       {Type: "string", Title: "正規表現", Format: "regex"},
+	"caseSensitive": {Type: "boolean", Title: "大文字と小文字を区別する"},
+})
+
+// ReactionTriggerSchema ReactionTriggerConfig のスキーマ
+var ReactionTriggerSchema = ObjectSchema(map[string]*Schema{
+	"emojis":     StringListSchema("反応する絵文字"),
+	"channelIds": StringListSchema("反応するチャンネルID"),
+})
+
+// MemberJoinTriggerSchema MemberJoinTriggerConfig のスキーマ
+var MemberJoinTriggerSchema = ObjectSchema(map[string]*Schema{
+	"guildIds": StringListSchema("反応するサーバーID"),
+})
+
+// ThreadCreateTriggerSchema ThreadCreateTriggerConfig のスキーマ
+var ThreadCreateTriggerSchema = ObjectSchema(map[string]*Schema{
+	"parentChannelIds": StringListSchema("反応する親チャンネルID"),
+})
+
+// TriggerNodeExecutor 条件の判定をトリガーの照合で済ませるトリガーノード共通の実行関数
+func TriggerNodeExecutor(props NodeProps) (NodeResult, error) {
+	return NodeResult{
+		Type:     props.Node.Type,
+		Continue: true,
+	}, nil
+}
+
+// MatchMessageTrigger チャンネルと投稿者の条件を判定します
+func MatchMessageTrigger(node models.Node, trigger *Trigger) bool {
+	var config MessageTriggerConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return false
+	}
+	return config.match(trigger)
+}
+
+func (c MessageTriggerConfig) match(trigger *Trigger) bool {
+	if trigger.Author != nil && trigger.Author.Bot && !c.IncludeBots {
+		return false
+	}
+	return matchChannel(c.ChannelIDs, trigger)
+}
+
+// MatchKeywordTrigger キーワードまたは正規表現に一致するかを判定します
+func MatchKeywordTrigger(node models.Node, trigger *Trigger) bool {
+	var config KeywordTriggerConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return false
+	}
+	if !config.match(trigger) {
+		return false
+	}
+
+	content := trigger.Content
+	if !config.CaseSensitive {
+		content = strings.ToLower(content)
+	}
+	for _, keyword := range config.Keywords {
+		if !config.CaseSensitive {
+			keyword = strings.ToLower(keyword)
+		}
+		if keyword != "" && strings.Contains(content, keyword) {
+			return true
+		}
+	}
+	if config.Pattern != "" {
+		pattern := config.Pattern
+		if !config.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		re := compileKeywordPattern(pattern)
+		return re != nil && re.MatchString(trigger.Content)
+	}
+	return false
+}
+
+// keywordPatterns コンパイル済みの keyword トリガーの正規表現。コンパイルできないパターンは nil
+var keywordPatterns sync.Map
+
+// compileKeywordPattern メッセージごとにコンパイルし直さないよう、パターンごとに一度だけコンパイルします
+func compileKeywordPattern(pattern string) *regexp.Regexp {
+	if re, ok := keywordPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = nil
+	}
+	keywordPatterns.Store(pattern, re)
+	return re
+}
+
+// MatchReactionTrigger 絵文字とチャンネルの条件を判定します
+func MatchReactionTrigger(node models.Node, trigger *Trigger) bool {
+	var config ReactionTriggerConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return false
+	}
+	if !matchChannel(config.ChannelIDs, trigger) {
+		return false
+	}
+	if len(config.Emojis) == 0 {
+		return true
+	}
+	for _, emoji := range config.Emojis {
+		// カスタム絵文字は name:id と name のどちらでも指定できる
+		if emoji == trigger.Emoji || strings.HasPrefix(trigger.Emoji, emoji+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchMemberJoinTrigger 参加したサーバーの条件を判定します
+func MatchMemberJoinTrigger(node models.Node, trigger *Trigger) bool {
+	var config MemberJoinTriggerConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return false
+	}
+	return len(config.GuildIDs) == 0 || contains(config.GuildIDs, trigger.GuildID)
+}
+
+// MatchThreadCreateTrigger スレッドの親チャンネルの条件を判定します
+func MatchThreadCreateTrigger(node models.Node, trigger *Trigger) bool {
+	var config ThreadCreateTriggerConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return false
+	}
+	return len(config.ParentChannelIDs) == 0 || contains(config.ParentChannelIDs, trigger.ParentChannelID)
+}
+
+// ValidateKeywordTriggerNode keyword トリガーの設定を検証します
+func ValidateKeywordTriggerNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config KeywordTriggerConfig
+	if err := DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+	if len(config.Keywords) == 0 && config.Pattern == "" {
+		return []models.ValidationError{{Field: "config.keywords", Message: "キーワードか正規表現のどちらかを指定してください"}}
+	}
+	if config.Pattern != "" {
+		if _, err := regexp.Compile(config.Pattern); err != nil {
+			return []models.ValidationError{{Field: "config.pattern", Message: err.Error()}}
+		}
+	}
+	return nil
+}
+
+// NewReactionTrigger リアクション追加イベントからトリガーを作成
+// 対象メッセージはAPI呼び出しが必要なため、loadReactionMessage で別途取得します
+func NewReactionTrigger(r *discordgo.MessageReactionAdd) *Trigger {
+	trigger := &Trigger{
+		Type:      TriggerReactionAdd,
+		ID:        r.MessageID,
+		GuildID:   r.GuildID,
+		ChannelID: r.ChannelID,
+		Emoji:     r.Emoji.APIName(),
+		Author:    &discordgo.User{ID: r.UserID},
+	}
+	if r.Member != nil && r.Member.User != nil {
+		trigger.Author = r.Member.User
+	}
+	return trigger
+}
+
+// loadReactionMessage リアクションしたユーザーと対象メッセージを取得して設定します
+func (t *Trigger) loadReactionMessage(client DiscordClient) {
+	if t.Author.Username == "" {
+		if user, err := client.User(t.Author.ID); err == nil {
+			t.Author = user
+		}
+	}
+	if message, err := client.Message(t.ChannelID, t.ID); err == nil {
+		t.Message = message
+		t.Content = message.Content
+	}
+}
+
+// NewMemberJoinTrigger メンバー参加イベントからトリガーを作成
+// 返信先としてサーバーのシステムチャンネルを使用します
+func NewMemberJoinTrigger(m *discordgo.GuildMemberAdd, systemChannelID string) *Trigger {
+	return &Trigger{
+		Type:      TriggerMemberJoin,
+		ID:        m.GuildID + "-" + m.User.ID,
+		GuildID:   m.GuildID,
+		ChannelID: systemChannelID,
+		Author:    m.User,
+	}
+}
+
+// NewThreadCreateTrigger スレッド作成イベントからトリガーを作成
+func NewThreadCreateTrigger(t *discordgo.ThreadCreate) *Trigger {
+	return &Trigger{
+		Type:            TriggerThreadCreate,
+		ID:              t.ID,
+		GuildID:         t.GuildID,
+		ChannelID:       t.ID,
+		ParentChannelID: t.ParentID,
+		Author:          &discordgo.User{ID: t.OwnerID},
+		Content:         t.Name,
+	}
+}
+
+// TriggerIntents フローで使われているトリガーに必要なGatewayインテントを返します
+// 特権インテントは必要な場合にのみ要求します
+func TriggerIntents(flows []models.FlowData) discordgo.Intent {
+	intents := discordgo.IntentsAllWithoutPrivileged
+	for _, flow := range flows {
+		for _, node := range flow.Nodes {
+			switch node.Type {
+			case TriggerMessageCreate, TriggerKeyword, TriggerMessageUpdate:
+				intents |= discordgo.IntentMessageContent
+			case TriggerMemberJoin:
+				intents |= discordgo.IntentGuildMembers
+			}
+		}
+	}
+	return intents
+}
+
+func matchChannel(channelIDs []string, trigger *Trigger) bool {
+	if len(channelIDs) == 0 {
+		return true
+	}
+	return contains(channelIDs, trigger.ChannelID) ||
+		(trigger.ParentChannelID != "" && contains(channelIDs, trigger.ParentChannelID))
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
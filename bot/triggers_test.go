@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func messageTrigger(content string) *Trigger {
+	return &Trigger{
+		Type:      TriggerMessageCreate,
+		GuildID:   "g1",
+		ChannelID: "c1",
+		Content:   content,
+		Author:    &discordgo.User{ID: "u1"},
+	}
+}
+
+func TestMatchMessageTrigger(t *testing.T) {
+	inThread := messageTrigger("hi")
+	inThread.ChannelID = "thread1"
+	inThread.ParentChannelID = "c1"
+	fromBot := messageTrigger("hi")
+	fromBot.Author = &discordgo.User{ID: "b1", Bot: true}
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		trigger *Trigger
+		want    bool
+	}{
+		{"any channel", nil, messageTrigger("hi"), true},
+		{"listed channel", map[string]interface{}{"channelIds": []interface{}{"c1"}}, messageTrigger("hi"), true},
+		{"other channel", map[string]interface{}{"channelIds": []interface{}{"c2"}}, messageTrigger("hi"), false},
+		{"thread of listed channel", map[string]interface{}{"channelIds": []interface{}{"c1"}}, inThread, true},
+		{"bot ignored", nil, fromBot, false},
+		{"bot included", map[string]interface{}{"includeBots": true}, fromBot, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := testNode("t", TriggerMessageCreate, tt.config)
+			if got := MatchMessageTrigger(node, tt.trigger); got != tt.want {
+				t.Errorf("MatchMessageTrigger = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchKeywordTrigger(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		content string
+		want    bool
+	}{
+		{"keyword", map[string]interface{}{"keywords": []interface{}{"help"}}, "please HELP me", true},
+		{"case sensitive keyword", map[string]interface{}{"keywords": []interface{}{"help"}, "caseSensitive": true}, "please HELP me", false},
+		{"no keyword", map[string]interface{}{"keywords": []interface{}{"help"}}, "hello", false},
+		{"empty keyword", map[string]interface{}{"keywords": []interface{}{""}}, "hello", false},
+		{"pattern", map[string]interface{}{"pattern": `^!roll \d+$`}, "!ROLL 20", true},
+		{"case sensitive pattern", map[string]interface{}{"pattern": `^!roll \d+$`, "caseSensitive": true}, "!ROLL 20", false},
+		{"invalid pattern", map[string]interface{}{"pattern": `(`}, "(", false},
+		{"other channel", map[string]interface{}{"keywords": []interface{}{"help"}, "channelIds": []interface{}{"c2"}}, "help", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := testNode("t", TriggerKeyword, tt.config)
+			// 2回目はコンパイル済みの正規表現を使う
+			for i := 0; i < 2; i++ {
+				if got := MatchKeywordTrigger(node, messageTrigger(tt.content)); got != tt.want {
+					t.Errorf("MatchKeywordTrigger(%q) = %v, want %v", tt.content, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchReactionTrigger(t *testing.T) {
+	tests := []struct {
+		name   string
+		emojis []interface{}
+		emoji  string
+		want   bool
+	}{
+		{"any emoji", nil, "👍", true},
+		{"unicode emoji", []interface{}{"👍"}, "👍", true},
+		{"other emoji", []interface{}{"👍"}, "👎", false},
+		{"custom emoji by name", []interface{}{"party"}, "party:123", true},
+		{"custom emoji by name and id", []interface{}{"party:123"}, "party:123", true},
+		{"name prefix only", []interface{}{"par"}, "party:123", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := testNode("t", TriggerReactionAdd, map[string]interface{}{"emojis": tt.emojis})
+			trigger := &Trigger{Type: TriggerReactionAdd, ChannelID: "c1", Emoji: tt.emoji}
+			if got := MatchReactionTrigger(node, trigger); got != tt.want {
+				t.Errorf("MatchReactionTrigger(%q) = %v, want %v", tt.emoji, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchMemberJoinAndThreadCreateTrigger(t *testing.T) {
+	join := &Trigger{Type: TriggerMemberJoin, GuildID: "g1"}
+	if !MatchMemberJoinTrigger(testNode("t", TriggerMemberJoin, nil), join) {
+		t.Error("memberJoin without guilds should match")
+	}
+	if MatchMemberJoinTrigger(testNode("t", TriggerMemberJoin, map[string]interface{}{"guildIds": []interface{}{"g2"}}), join) {
+		t.Error("memberJoin in another guild should not match")
+	}
+
+	thread := &Trigger{Type: TriggerThreadCreate, ChannelID: "thread1", ParentChannelID: "c1"}
+	if !MatchThreadCreateTrigger(testNode("t", TriggerThreadCreate, map[string]interface{}{"parentChannelIds": []interface{}{"c1"}}), thread) {
+		t.Error("threadCreate under a listed channel should match")
+	}
+	if MatchThreadCreateTrigger(testNode("t", TriggerThreadCreate, map[string]interface{}{"parentChannelIds": []interface{}{"c2"}}), thread) {
+		t.Error("threadCreate under another channel should not match")
+	}
+}
+
+func TestThreadParentID(t *testing.T) {
+	state := discordgo.NewState()
+	if err := state.GuildAdd(&discordgo.Guild{ID: "g1"}); err != nil {
+		t.Fatal(err)
+	}
+	channels := []*discordgo.Channel{
+		{ID: "c1", GuildID: "g1", Type: discordgo.ChannelTypeGuildText},
+		{ID: "thread1", GuildID: "g1", ParentID: "c1", Type: discordgo.ChannelTypeGuildPublicThread},
+	}
+	for _, channel := range channels {
+		if err := state.ChannelAdd(channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for channelID, want := range map[string]string{"thread1": "c1", "c1": "", "unknown": ""} {
+		if got := threadParentID(state, channelID); got != want {
+			t.Errorf("threadParentID(%q) = %q, want %q", channelID, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"fmt"
+)
+
+// ValidateFlow フローのグラフ構造と各ノードの設定を検証します
+// 問題がない場合は空のスライスを返します
+func (fe *FlowExecutor) ValidateFlow(ctx context.Context, flow models.FlowData) []models.ValidationError {
+	errs := []models.ValidationError{}
+
+	nodeIDs := make(map[string]bool, len(flow.Nodes))
+	var triggers []string
+	for _, node := range flow.Nodes {
+		if node.ID == "" {
+			errs = append(errs, models.ValidationError{Field: "id", Message: "ノードIDが空です"})
+			continue
+		}
+		if nodeIDs[node.ID] {
+			errs = append(errs, models.ValidationError{NodeID: node.ID, Field: "id", Message: "ノードIDが重複しています"})
+			continue
+		}
+		nodeIDs[node.ID] = true
+
+		registration, ok := fe.nodeExecutors[node.Type]
+		if !ok {
+			errs = append(errs, models.ValidationError{
+				NodeID:  node.ID,
+				Field:   "type",
+				Message: fmt.Sprintf("未登録のノードタイプ %q です", node.Type),
+			})
+			continue
+		}
+		if registration.trigger {
+			triggers = append(triggers, node.ID)
+		}
+		if registration.join {
+			if len(incomingSources(flow.Edges, node.ID)) < 2 {
+				errs = append(errs, models.ValidationError{NodeID: node.ID, Message: "合流ノードには2つ以上のノードを接続してください"})
+			}
+		}
+		if node.Data.Timeout < 0 {
+			errs = append(errs, models.ValidationError{NodeID: node.ID, Field: "timeout", Message: "タイムアウトは0以上で指定してください"})
+		}
+		// スキーマに従わない設定は個別の検証関数に渡さない
+		var configErrs []models.ValidationError
+		if registration.schema != nil {
+			configErrs = registration.schema.ValidateConfig(node.Data.Config)
+		}
+		if len(configErrs) == 0 && registration.validator != nil {
+			configErrs = registration.validator(ctx, node)
+		}
+		for _, e := range configErrs {
+			e.NodeID = node.ID
+			errs = append(errs, e)
+		}
+	}
+
+	errs = append(errs, validateSettings(flow.Settings)...)
+
+	if len(triggers) == 0 {
+		errs = append(errs, models.ValidationError{Message: "スタートノード（トリガー）がありません"})
+	}
+
+	edgeIDs := make(map[string]bool, len(flow.Edges))
+	for _, edge := range flow.Edges {
+		if edge.ID != "" {
+			if edgeIDs[edge.ID] {
+				errs = append(errs, models.ValidationError{EdgeID: edge.ID, Field: "id", Message: "エッジIDが重複しています"})
+			}
+			edgeIDs[edge.ID] = true
+		}
+		if !nodeIDs[edge.Source] {
+			errs = append(errs, models.ValidationError{
+				EdgeID:  edge.ID,
+				Field:   "source",
+				Message: fmt.Sprintf("接続元ノード %q が存在しません", edge.Source),
+			})
+		}
+		if !nodeIDs[edge.Target] {
+			errs = append(errs, models.ValidationError{
+				EdgeID:  edge.ID,
+				Field:   "target",
+				Message: fmt.Sprintf("接続先ノード %q が存在しません", edge.Target),
+			})
+		}
+		if edge.Condition != "" {
+			if _, err := ParseExpression(edge.Condition); err != nil {
+				errs = append(errs, models.ValidationError{EdgeID: edge.ID, Field: "condition", Message: err.Error()})
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateSettings(settings models.FlowSettings) []models.ValidationError {
+	var errs []models.ValidationError
+	switch settings.ExecutionMode {
+	case "", models.ExecutionSequential, models.ExecutionParallel:
+	default:
+		errs = append(errs, models.ValidationError{
+			Field:   "settings.executionMode",
+			Message: fmt.Sprintf("未対応の実行方法 %q です", settings.ExecutionMode),
+		})
+	}
+	if settings.MaxParallelism < 0 {
+		errs = append(errs, models.ValidationError{Field: "settings.maxParallelism", Message: "並行数は0以上で指定してください"})
+	}
+	return errs
+}
+
+// ValidateExpressionField 設定中の条件式を検証するためのヘルパー
+func ValidateExpressionField(field, source string) []models.ValidationError {
+	if source == "" {
+		return []models.ValidationError{{Field: field, Message: "条件式が空です"}}
+	}
+	if _, err := ParseExpression(source); err != nil {
+		return []models.ValidationError{{Field: field, Message: err.Error()}}
+	}
+	return nil
+}
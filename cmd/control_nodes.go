@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"discord-bot-service/bot"
+	"discord-bot-service/internal/models"
+	"fmt"
+)
+
+// ifNodeConfig ifノードの設定
+type ifNodeConfig struct {
+	Condition string `json:"condition"`
+}
+
+// switchNodeConfig switchノードの設定
+type switchNodeConfig struct {
+	Cases []switchCase `json:"cases"`
+}
+
+type switchCase struct {
+	Handle    string `json:"handle"`
+	Condition string `json:"condition"`
+}
+
+var ifNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"condition": {Type: "string", Title: "条件式", Format: "expression"},
+}, "condition")
+
+var switchNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"cases": {
+		Type:  "array",
+		Title: "ケース",
+		Items: bot.ObjectSchema(map[string]*bot.Schema{
+			"handle":    bot.StringSchema("出力ハンドル"),
+			"condition": {Type: "string", Title: "条件式", Format: "expression"},
+		}, "handle", "condition"),
+	},
+})
+
+// ifNodeExecutor 条件式を評価して "true" または "false" ハンドルへ分岐します
+func ifNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config ifNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	ok, err := bot.EvalCondition(config.Condition, props.Exec.Variables)
+	if err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	handle := "false"
+	if ok {
+		handle = "true"
+	}
+	return bot.NodeResult{
+		Type:     "if",
+		Continue: true,
+		Handle:   handle,
+		Output:   map[string]interface{}{"result": ok},
+	}, nil
+}
+
+// switchNodeExecutor 最初に条件を満たしたケースのハンドルへ分岐します
+// どのケースにも一致しない場合は "default" ハンドルへ進みます
+func switchNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config switchNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	handle := "default"
+	for _, c := range config.Cases {
+		ok, err := bot.EvalCondition(c.Condition, props.Exec.Variables)
+		if err != nil {
+			return bot.NodeResult{}, err
+		}
+		if ok {
+			handle = c.Handle
+			break
+		}
+	}
+	return bot.NodeResult{
+		Type:     "switch",
+		Continue: true,
+		Handle:   handle,
+		Output:   map[string]interface{}{"handle": handle},
+	}, nil
+}
+
+func validateIfNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config ifNodeConfig
+	if err := bot.DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+	return bot.ValidateExpressionField("config.condition", config.Condition)
+}
+
+func validateSwitchNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config switchNodeConfig
+	if err := bot.DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+
+	var errs []models.ValidationError
+	for i, c := range config.Cases {
+		field := fmt.Sprintf("config.cases[%d]", i)
+		if c.Handle == "" || c.Handle == "default" || c.Handle == bot.HandleError {
+			errs = append(errs, models.ValidationError{Field: field + ".handle", Message: "ハンドル名が空か予約名です"})
+		}
+		errs = append(errs, bot.ValidateExpressionField(field+".condition", c.Condition)...)
+	}
+	return errs
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"discord-bot-service/bot"
+	"discord-bot-service/dify"
+	"discord-bot-service/internal/models"
+	"fmt"
+	"log"
+)
+
+// difyResult アプリの種類によらない問い合わせの結果
+type difyResult struct {
+	answer string
+	// conversationID チャットアプリの場合のみ
+	conversationID string
+	// outputs ワークフローの場合のみ
+	outputs map[string]interface{}
+}
+
+// simulateDify シミュレーション実行で、Difyに問い合わせずに仮の回答を返します
+// 会話の取得や保存も行いません
+func simulateDify(app *models.NodeDify, query string) *difyResult {
+	result := &difyResult{answer: fmt.Sprintf("（シミュレーション）Difyアプリ %s の回答: %s", app.Name, query)}
+	if app.AppType == models.DifyAppWorkflow {
+		result.outputs = map[string]interface{}{}
+	}
+	return result
+}
+
+// runDifyChat チャットアプリに問い合わせ、会話を続けられるよう会話IDを保存します
+func runDifyChat(props bot.NodeProps, config difyNodeConfig, target bot.ReplyTarget, app *models.NodeDify, request dify.RequestBody) (*difyResult, error) {
+	scope := config.conversationScope(props.Trigger, target.ChannelID)
+	if scope != "" {
+		conversationID, err := conversationService.GetConversationID(props.Context, app.Name, scope)
+		if err != nil {
+			return nil, err
+		}
+		request.ConversationID = conversationID
+	}
+
+	var (
+		response *dify.ResponseBody
+		err      error
+	)
+	if config.Streaming {
+		err = streamDifyAnswer(props, target, app, func(onDelta func(string) error) (string, error) {
+			response, err = dify.StreamMessage(props.Context, app.Url, app.Token, request, onDelta)
+			if err != nil {
+				return "", err
+			}
+			return response.Answer, nil
+		})
+	} else {
+		props.Client.Typing(target.ChannelID)
+		response, err = dify.GenerateMessage(props.Context, app.Url, app.Token, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if scope != "" {
+		if err := conversationService.SaveConversation(props.Context, app.Name, scope, target.ChannelID, request.User, response.ConversationID); err != nil {
+			// 回答は得られているため、保存に失敗しても次の問い合わせが新しい会話になるだけにとどめる
+			log.Printf("Failed to save dify conversation: %v", err)
+		}
+	}
+	return &difyResult{answer: response.Answer, conversationID: response.ConversationID}, nil
+}
+
+// completionInputs テキスト生成アプリでは本文を入力変数 query として渡します
+// 入力変数の対応で query を指定している場合はそちらを優先します
+func completionInputs(inputs map[string]interface{}, content string) map[string]interface{} {
+	if _, ok := inputs["query"]; !ok {
+		inputs["query"] = content
+	}
+	return inputs
+}
+
+// runDifyCompletion テキスト生成アプリで文章を生成します
+func runDifyCompletion(props bot.NodeProps, config difyNodeConfig, target bot.ReplyTarget, app *models.NodeDify, request dify.RunRequest) (*difyResult, error) {
+	var (
+		response *dify.ResponseBody
+		err      error
+	)
+	if config.Streaming {
+		err = streamDifyAnswer(props, target, app, func(onDelta func(string) error) (string, error) {
+			response, err = dify.StreamCompletion(props.Context, app.Url, app.Token, request, onDelta)
+			if err != nil {
+				return "", err
+			}
+			return response.Answer, nil
+		})
+	} else {
+		props.Client.Typing(target.ChannelID)
+		response, err = dify.GenerateCompletion(props.Context, app.Url, app.Token, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &difyResult{answer: response.Answer}, nil
+}
+
+// runDifyWorkflow ワークフローを実行します
+// ストリーミングではテキストの出力を、それ以外では answer または text の出力を回答として扱います
+func runDifyWorkflow(props bot.NodeProps, config difyNodeConfig, target bot.ReplyTarget, app *models.NodeDify, request dify.RunRequest) (*difyResult, error) {
+	var (
+		result *dify.WorkflowResult
+		answer string
+		err    error
+	)
+	if config.Streaming {
+		err = streamDifyAnswer(props, target, app, func(onDelta func(string) error) (string, error) {
+			result, err = dify.StreamWorkflow(props.Context, app.Url, app.Token, request, func(delta string) error {
+				answer += delta
+				return onDelta(delta)
+			})
+			if err != nil {
+				return "", err
+			}
+			if answer == "" {
+				answer = workflowAnswer(result.Data.Outputs)
+			}
+			return answer, nil
+		})
+	} else {
+		props.Client.Typing(target.ChannelID)
+		result, err = dify.RunWorkflow(props.Context, app.Url, app.Token, request)
+		if err == nil {
+			answer = workflowAnswer(result.Data.Outputs)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	outputs := result.Data.Outputs
+	if outputs == nil {
+		outputs = map[string]interface{}{}
+	}
+	return &difyResult{answer: answer, outputs: outputs}, nil
+}
+
+// workflowAnswer 出力のうち回答として投稿する文字列を返します
+func workflowAnswer(outputs map[string]interface{}) string {
+	for _, name := range []string{"answer", "text"} {
+		if text, ok := outputs[name].(string); ok {
+			return text
+		}
+	}
+	return ""
+}
+
+// streamDifyAnswer ストリーミングで回答を受け取り、届いた分を送信したメッセージに反映します
+// stream は受け取った回答全体を返し、差分が届かなかった場合はそれを投稿します
+func streamDifyAnswer(props bot.NodeProps, target bot.ReplyTarget, app *models.NodeDify, stream func(onDelta func(string) error) (string, error)) error {
+	writer := newStreamWriter(props.Client, target, func(s string) string {
+		return addDomain(app.Url, s)
+	})
+	if err := writer.start(); err != nil {
+		return err
+	}
+	answer, err := stream(writer.write)
+	if err != nil {
+		if failErr := writer.fail(); failErr != nil {
+			log.Printf("Failed to show stream error: %v", failErr)
+		}
+		return err
+	}
+	return writer.close(answer)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"discord-bot-service/bot"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// streamEditInterval 編集の最短間隔。Discordのレート制限（5回/5秒）に収まるようにする
+	streamEditInterval = 1500 * time.Millisecond
+	// streamPlaceholder 最初の差分が届くまで表示する本文
+	streamPlaceholder = "…"
+	// streamErrorNotice 回答の途中で失敗した場合に表示する本文
+	streamErrorNotice = "⚠️ 回答の取得中にエラーが発生しました"
+	// streamEmptyNotice 回答が空だった場合に仮の本文と置き換える本文
+	streamEmptyNotice = "（回答はありませんでした）"
+)
+
+// streamWriter 届いた回答を送信済みのメッセージへの編集として反映します
+// 上限の文字数を超えた分は新しいメッセージとして続けて送信します
+type streamWriter struct {
+	client bot.DiscordClient
+	target bot.ReplyTarget
+	// format 表示する前に本文へ適用する変換
+	format   func(string) string
+	interval time.Duration
+
+	messageID string
+	// current 表示中のメッセージの本文
+	current  string
+	shown    string
+	lastEdit time.Time
+	sent     int
+	// written 差分が1つ以上届いたか
+	written bool
+}
+
+func newStreamWriter(client bot.DiscordClient, target bot.ReplyTarget, format func(string) string) *streamWriter {
+	return &streamWriter{client: client, target: target, format: format, interval: streamEditInterval}
+}
+
+// start 差分を反映するための仮のメッセージを送信します
+func (w *streamWriter) start() error {
+	return w.send(streamPlaceholder)
+}
+
+// write 回答の差分を追加し、前回の編集から間隔が空いていれば反映します
+func (w *streamWriter) write(delta string) error {
+	w.written = true
+	w.current += delta
+	for utf8.RuneCountInString(w.format(w.current)) > bot.MaxMessageContent {
+		head, rest := w.cut(w.current)
+		if err := w.edit(head); err != nil {
+			return err
+		}
+		w.current = rest
+		if err := w.send(orPlaceholder(rest)); err != nil {
+			return err
+		}
+	}
+	if time.Since(w.lastEdit) < w.interval {
+		return nil
+	}
+	return w.edit(w.current)
+}
+
+// close 残りの本文を反映します
+// 差分が1つも届かなかった場合は answer を本文とし、それも空の場合は仮の本文を streamEmptyNotice に置き換えます
+func (w *streamWriter) close(answer string) error {
+	if !w.written && answer != "" {
+		if err := w.write(answer); err != nil {
+			return err
+		}
+	}
+	if w.sent == 1 && strings.TrimSpace(w.current) == "" {
+		return w.edit(streamEmptyNotice)
+	}
+	return w.edit(w.current)
+}
+
+// fail 回答の途中で失敗した場合に、仮の本文をエラーの通知に置き換えます
+// それまでに届いた本文は残し、その後ろに通知を追加します
+func (w *streamWriter) fail() error {
+	content := streamErrorNotice
+	if strings.TrimSpace(w.current) != "" {
+		content = w.current + "\n\n" + streamErrorNotice
+	}
+	if utf8.RuneCountInString(w.format(content)) <= bot.MaxMessageContent {
+		return w.edit(content)
+	}
+	if err := w.edit(w.current); err != nil {
+		return err
+	}
+	return w.send(streamErrorNotice)
+}
+
+// cut 上限に収まる先頭部分と残りに分けます。可能な限り改行か空白で区切ります
+func (w *streamWriter) cut(text string) (string, string) {
+	runes := []rune(text)
+	n := bot.MaxMessageContent
+	if n > len(runes) {
+		n = len(runes)
+	}
+	// format で本文が長くなる場合に備えて上限に収まるまで縮める
+	for n > 1 && utf8.RuneCountInString(w.format(string(runes[:n]))) > bot.MaxMessageContent {
+		n -= max(1, n/10)
+	}
+	head := string(runes[:n])
+	if i := strings.LastIndexAny(head, "\n "); i > len(head)/2 {
+		return head[:i], strings.TrimLeft(text[i:], "\n ")
+	}
+	return head, string(runes[n:])
+}
+
+func (w *streamWriter) send(content string) error {
+	data := &discordgo.MessageSend{Content: w.format(content)}
+	// 返信の参照は最初のメッセージにのみ付ける
+	if w.sent == 0 {
+		w.target.Apply(data)
+	}
+	message, err := w.client.SendComplex(w.target.ChannelID, data)
+	if err != nil {
+		return err
+	}
+	w.messageID = message.ID
+	w.shown = content
+	w.lastEdit = time.Now()
+	w.sent++
+	return nil
+}
+
+func (w *streamWriter) edit(content string) error {
+	// 空の本文には編集できないため仮の本文のまま残す
+	if strings.TrimSpace(content) == "" || content == w.shown {
+		return nil
+	}
+	if _, err := w.client.EditMessage(w.target.ChannelID, w.messageID, w.format(content)); err != nil {
+		return err
+	}
+	w.shown = content
+	w.lastEdit = time.Now()
+	return nil
+}
+
+func orPlaceholder(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return streamPlaceholder
+	}
+	return s
+}
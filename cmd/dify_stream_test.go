@@ -0,0 +1,147 @@
+package main
+
+import (
+	"discord-bot-service/bot"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func identity(s string) string {
+	return s
+}
+
+func TestStreamWriterCut(t *testing.T) {
+	long := strings.Repeat("a", bot.MaxMessageContent+500)
+	spaced := strings.Repeat("a", 1500) + "\n" + strings.Repeat("b", 1000)
+	tests := []struct {
+		name     string
+		format   func(string) string
+		text     string
+		wantHead string
+		wantRest string
+	}{
+		{"short", identity, "hello", "hello", ""},
+		{"no separator", identity, long, long[:bot.MaxMessageContent], long[bot.MaxMessageContent:]},
+		{"newline", identity, spaced, spaced[:1500], spaced[1501:]},
+		{"separator too early", identity, "a " + long, ("a " + long)[:bot.MaxMessageContent], ("a " + long)[bot.MaxMessageContent:]},
+		{"multibyte", identity, strings.Repeat("あ", bot.MaxMessageContent+1), strings.Repeat("あ", bot.MaxMessageContent), "あ"},
+		// どれだけ縮めても収まらない場合も1文字で止まる
+		{"format never fits", func(s string) string { return s + long }, "abcdefghij", "a", "bcdefghij"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &streamWriter{format: tt.format}
+			head, rest := w.cut(tt.text)
+			if head != tt.wantHead || rest != tt.wantRest {
+				t.Errorf("cut = (%d runes, %d runes), want (%d runes, %d runes)",
+					utf8.RuneCountInString(head), utf8.RuneCountInString(rest),
+					utf8.RuneCountInString(tt.wantHead), utf8.RuneCountInString(tt.wantRest))
+			}
+		})
+	}
+}
+
+func TestStreamWriterCutGrowingFormat(t *testing.T) {
+	// format で長くなる分だけ先頭部分を縮める
+	w := &streamWriter{format: func(s string) string { return s + s }}
+	text := strings.Repeat("a", bot.MaxMessageContent+500)
+	head, rest := w.cut(text)
+	if n := utf8.RuneCountInString(w.format(head)); n > bot.MaxMessageContent {
+		t.Errorf("formatted head has %d runes, want at most %d", n, bot.MaxMessageContent)
+	}
+	if head+rest != text {
+		t.Error("head and rest should make up the whole text")
+	}
+}
+
+// streamMessages メッセージごとに最後に表示された本文を送信順に返します
+func streamMessages(client *bot.FakeClient) []string {
+	var ids []string
+	contents := make(map[string]string)
+	for _, action := range client.Actions() {
+		switch action.Type {
+		case "sendMessage":
+			ids = append(ids, action.MessageID)
+			contents[action.MessageID] = action.Content
+		case "editMessage":
+			contents[action.MessageID] = action.Content
+		}
+	}
+	messages := make([]string, len(ids))
+	for i, id := range ids {
+		messages[i] = contents[id]
+	}
+	return messages
+}
+
+func TestStreamWriter(t *testing.T) {
+	long := strings.Repeat("a", bot.MaxMessageContent)
+	tests := []struct {
+		name   string
+		deltas []string
+		answer string
+		want   []string
+	}{
+		{"deltas", []string{"Hello", ", ", "world"}, "Hello, world", []string{"Hello, world"}},
+		{"answer without deltas", nil, "Hello", []string{"Hello"}},
+		{"empty answer", nil, "", []string{streamEmptyNotice}},
+		{"blank deltas", []string{" ", "\n"}, " \n", []string{streamEmptyNotice}},
+		{"rollover", []string{long[:1500], long[:1500]}, "", []string{long, long[:1000]}},
+		{"rollover at separator", []string{long[:1500] + "\n", long[:600]}, "", []string{long[:1500], long[:600]}},
+		{"answer without deltas rolls over", nil, long + "bc", []string{long, "bc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := bot.NewFakeClient("bot")
+			w := newStreamWriter(client, bot.ReplyTarget{ChannelID: "c1"}, identity)
+			if err := w.start(); err != nil {
+				t.Fatal(err)
+			}
+			for _, delta := range tt.deltas {
+				if err := w.write(delta); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.close(tt.answer); err != nil {
+				t.Fatal(err)
+			}
+			if got := streamMessages(client); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamWriterFail(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		want   []string
+	}{
+		{"before any delta", nil, []string{streamErrorNotice}},
+		{"after deltas", []string{"Hello"}, []string{"Hello\n\n" + streamErrorNotice}},
+		{"notice does not fit", []string{strings.Repeat("a", bot.MaxMessageContent-1)}, []string{strings.Repeat("a", bot.MaxMessageContent-1), streamErrorNotice}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := bot.NewFakeClient("bot")
+			w := newStreamWriter(client, bot.ReplyTarget{ChannelID: "c1"}, identity)
+			if err := w.start(); err != nil {
+				t.Fatal(err)
+			}
+			for _, delta := range tt.deltas {
+				if err := w.write(delta); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.fail(); err != nil {
+				t.Fatal(err)
+			}
+			if got := streamMessages(client); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
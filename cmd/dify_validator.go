@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"discord-bot-service/bot"
+	"discord-bot-service/dify"
+	"discord-bot-service/internal/models"
+	"discord-bot-service/internal/repository/mongodb"
+	"discord-bot-service/internal/service"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// difyParametersTimeout 検証時にDifyアプリの設定を取得する時間の上限
+	difyParametersTimeout = 5 * time.Second
+	// difyParametersTTL 取得したアプリの設定を再利用する期間
+	difyParametersTTL = 5 * time.Minute
+	// difyParametersRetry 設定を取得できなかったアプリに再び問い合わせるまでの期間
+	difyParametersRetry = 30 * time.Second
+)
+
+// difyValidator dify ノードの設定を検証します
+// Difyアプリの入力フォームはアプリごとにキャッシュし、保存や検証のたびに問い合わせないようにします
+type difyValidator struct {
+	apps *service.NodeDifyService
+
+	mu     sync.Mutex
+	params map[string]cachedParameters
+}
+
+// cachedParameters 取得したアプリの設定。取得に失敗した場合は err を保持します
+type cachedParameters struct {
+	url       string
+	token     string
+	params    *dify.Parameters
+	err       error
+	expiresAt time.Time
+}
+
+func newDifyValidator(apps *service.NodeDifyService) *difyValidator {
+	return &difyValidator{apps: apps, params: make(map[string]cachedParameters)}
+}
+
+func (v *difyValidator) validateNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config difyNodeConfig
+	if err := bot.DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+	errs := bot.ValidateReplyOptions("config.reply", config.Reply)
+	for _, name := range sortedKeys(config.Inputs) {
+		errs = append(errs, bot.ValidateTemplateField("config.inputs."+name, config.Inputs[name])...)
+	}
+	return append(errs, v.validateInputs(ctx, config.appName(node), config.Inputs)...)
+}
+
+// validateInputs 入力変数の対応をDifyアプリの入力フォームと照合します
+// Difyに接続できない場合は保存を妨げないよう照合を省略します
+func (v *difyValidator) validateInputs(ctx context.Context, app string, inputs map[string]string) []models.ValidationError {
+	if app == "" {
+		return nil
+	}
+	botConfig, err := v.apps.GetNodeDifyByName(ctx, app)
+	if errors.Is(err, mongodb.ErrNotFound) {
+		return []models.ValidationError{{Field: "config.app", Message: fmt.Sprintf("Difyアプリ %s が登録されていません", app)}}
+	}
+	if err != nil {
+		log.Printf("Failed to load dify app %s: %v", app, err)
+		return nil
+	}
+	params, err := v.parameters(ctx, botConfig)
+	if err != nil {
+		log.Printf("Failed to fetch parameters of dify app %s: %v", app, err)
+		return nil
+	}
+
+	var errs []models.ValidationError
+	for _, name := range sortedKeys(inputs) {
+		if _, ok := params.Field(name); !ok {
+			errs = append(errs, models.ValidationError{Field: "config.inputs." + name, Message: fmt.Sprintf("Difyアプリ %s に入力変数 %s はありません", app, name)})
+		}
+	}
+	for _, field := range params.UserInputForm {
+		// テキスト生成アプリの query には本文が入る
+		if botConfig.AppType == models.DifyAppCompletion && field.Variable == "query" {
+			continue
+		}
+		if _, ok := inputs[field.Variable]; field.Required && !ok {
+			errs = append(errs, models.ValidationError{Field: "config.inputs." + field.Variable, Message: fmt.Sprintf("Difyアプリ %s の必須の入力変数 %s を指定してください", app, field.Variable)})
+		}
+	}
+	return errs
+}
+
+// parameters アプリの設定をキャッシュから返し、期限切れか接続先が変わった場合は取得し直します
+// 取得に失敗した場合も、しばらくは問い合わせずに同じエラーを返します
+func (v *difyValidator) parameters(ctx context.Context, app *models.NodeDify) (*dify.Parameters, error) {
+	v.mu.Lock()
+	cached, ok := v.params[app.Name]
+	v.mu.Unlock()
+	if ok && cached.url == app.Url && cached.token == app.Token && time.Now().Before(cached.expiresAt) {
+		return cached.params, cached.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, difyParametersTimeout)
+	defer cancel()
+	params, err := dify.GetParameters(ctx, app.Url, app.Token)
+	ttl := difyParametersTTL
+	if err != nil {
+		ttl = difyParametersRetry
+	}
+
+	v.mu.Lock()
+	v.params[app.Name] = cachedParameters{
+		url:       app.Url,
+		token:     app.Token,
+		params:    params,
+		err:       err,
+		expiresAt: time.Now().Add(ttl),
+	}
+	v.mu.Unlock()
+	return params, err
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"discord-bot-service/bot"
+	"discord-bot-service/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMaxIterations ループ回数の指定がない場合の上限
+	defaultMaxIterations = 100
+	// maxIterationsLimit 設定できるループ回数の上限
+	maxIterationsLimit = 1000
+)
+
+// forEachNodeConfig forEachノードの設定
+type forEachNodeConfig struct {
+	// List 繰り返す対象の変数名。文字列の場合は行ごとに分割します
+	List          string `json:"list"`
+	ItemVariable  string `json:"itemVariable"`
+	IndexVariable string `json:"indexVariable"`
+	MaxIterations int    `json:"maxIterations"`
+}
+
+// whileNodeConfig whileノードの設定
+type whileNodeConfig struct {
+	Condition     string `json:"condition"`
+	IndexVariable string `json:"indexVariable"`
+	MaxIterations int    `json:"maxIterations"`
+}
+
+// retryNodeConfig retryノードの設定
+type retryNodeConfig struct {
+	MaxAttempts int `json:"maxAttempts"`
+	// Until 成功とみなす条件式。空の場合は本体がエラーなく終了すれば成功
+	Until           string  `json:"until"`
+	DelaySeconds    float64 `json:"delaySeconds"`
+	AttemptVariable string  `json:"attemptVariable"`
+}
+
+var maxIterationsSchema = &bot.Schema{
+	Type:    "integer",
+	Title:   "最大反復回数",
+	Minimum: floatPtr(1),
+	Maximum: floatPtr(maxIterationsLimit),
+	Default: defaultMaxIterations,
+}
+
+var forEachNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"list":          {Type: "string", Title: "リストの変数名", Description: "文字列の場合は行ごとに繰り返します"},
+	"itemVariable":  {Type: "string", Title: "要素の変数名", Default: "item"},
+	"indexVariable": {Type: "string", Title: "番号の変数名", Default: "index"},
+	"maxIterations": maxIterationsSchema,
+}, "list")
+
+var whileNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"condition":     {Type: "string", Title: "継続条件", Format: "expression"},
+	"indexVariable": {Type: "string", Title: "番号の変数名", Default: "index"},
+	"maxIterations": maxIterationsSchema,
+}, "condition")
+
+var retryNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"maxAttempts":     {Type: "integer", Title: "最大試行回数", Minimum: floatPtr(1), Maximum: floatPtr(10), Default: 3},
+	"until":           {Type: "string", Title: "成功条件", Format: "expression"},
+	"delaySeconds":    {Type: "number", Title: "再試行までの待ち時間（秒）", Minimum: floatPtr(0), Maximum: floatPtr(60)},
+	"attemptVariable": {Type: "string", Title: "試行回数の変数名", Default: "attempt"},
+})
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func maxIterations(n int) int {
+	if n <= 0 {
+		return defaultMaxIterations
+	}
+	return min(n, maxIterationsLimit)
+}
+
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// forEachNodeExecutor リストの要素ごとに "body" ハンドルの先を実行し、終了後 "done" ハンドルへ進みます
+func forEachNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config forEachNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	value, _ := bot.LookupVariable(props.Exec.Variables, config.List)
+	items := listItems(value)
+	limit := maxIterations(config.MaxIterations)
+	if len(items) > limit {
+		return bot.NodeResult{}, fmt.Errorf("リストの要素数 %d が最大反復回数 %d を超えています", len(items), limit)
+	}
+
+	itemVariable := orDefault(config.ItemVariable, "item")
+	indexVariable := orDefault(config.IndexVariable, "index")
+	for i, item := range items {
+		props.Exec.Variables.Set(itemVariable, item)
+		props.Exec.Variables.Set(indexVariable, i)
+		if err := props.RunBranch("body", i); err != nil {
+			return bot.NodeResult{}, err
+		}
+	}
+
+	return bot.NodeResult{
+		Type:     "forEach",
+		Continue: true,
+		Handle:   "done",
+		Output:   map[string]interface{}{"count": len(items)},
+	}, nil
+}
+
+// listItems 変数の値を繰り返し可能な要素の列に変換します
+func listItems(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items
+	case string:
+		var items []interface{}
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				items = append(items, line)
+			}
+		}
+		return items
+	}
+	return []interface{}{value}
+}
+
+// whileNodeExecutor 条件を満たす間 "body" ハンドルの先を繰り返し実行し、終了後 "done" ハンドルへ進みます
+func whileNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config whileNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	limit := maxIterations(config.MaxIterations)
+	indexVariable := orDefault(config.IndexVariable, "index")
+	iterations := 0
+	for {
+		props.Exec.Variables.Set(indexVariable, iterations)
+		ok, err := bot.EvalCondition(config.Condition, props.Exec.Variables)
+		if err != nil {
+			return bot.NodeResult{}, err
+		}
+		if !ok {
+			break
+		}
+		if iterations >= limit {
+			return bot.NodeResult{}, fmt.Errorf("最大反復回数 %d に達しました", limit)
+		}
+		if err := props.RunBranch("body", iterations); err != nil {
+			return bot.NodeResult{}, err
+		}
+		iterations++
+	}
+
+	return bot.NodeResult{
+		Type:     "while",
+		Continue: true,
+		Handle:   "done",
+		Output:   map[string]interface{}{"iterations": iterations},
+	}, nil
+}
+
+// retryNodeExecutor "body" ハンドルの先が成功するまで再試行します
+// 成功した場合は "done"、すべての試行が失敗した場合は "failed" ハンドルへ進みます
+func retryNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config retryNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	maxAttempts := config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	attemptVariable := orDefault(config.AttemptVariable, "attempt")
+	delay := time.Duration(config.DelaySeconds * float64(time.Second))
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 && delay > 0 {
+			if err := sleepContext(props.Context, delay); err != nil {
+				return bot.NodeResult{}, err
+			}
+		}
+
+		props.Exec.Variables.Set(attemptVariable, attempt)
+		lastErr = props.RunBranch("body", attempt)
+		if lastErr == nil && config.Until != "" {
+			ok, err := bot.EvalCondition(config.Until, props.Exec.Variables)
+			if err != nil {
+				return bot.NodeResult{}, err
+			}
+			if !ok {
+				lastErr = fmt.Errorf("成功条件を満たしませんでした")
+			}
+		}
+		if lastErr == nil {
+			return bot.NodeResult{
+				Type:     "retry",
+				Continue: true,
+				Handle:   "done",
+				Output:   map[string]interface{}{"attempts": attempt},
+			}, nil
+		}
+		// フロー自体が中断された場合は再試行しない
+		if err := props.Context.Err(); err != nil {
+			return bot.NodeResult{}, err
+		}
+	}
+
+	return bot.NodeResult{
+		Type:     "retry",
+		Continue: true,
+		Handle:   "failed",
+		Output:   map[string]interface{}{"attempts": maxAttempts, "error": lastErr.Error()},
+	}, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func validateWhileNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config whileNodeConfig
+	if err := bot.DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+	return bot.ValidateExpressionField("config.condition", config.Condition)
+}
+
+func validateRetryNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config retryNodeConfig
+	if err := bot.DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+	if config.Until == "" {
+		return nil
+	}
+	return bot.ValidateExpressionField("config.until", config.Until)
+}
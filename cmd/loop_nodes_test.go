@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"discord-bot-service/bot"
+	"discord-bot-service/internal/models"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func newLoopTestExecutor() *bot.FlowExecutor {
+	fe := bot.NewFlowExecutor()
+	registerNodeExecutors(fe, nil)
+	fe.RegisterNodeExecutor("fail", func(props bot.NodeProps) (bot.NodeResult, error) {
+		return bot.NodeResult{}, errors.New("failed")
+	})
+	return fe
+}
+
+func loopNode(id, nodeType string, config map[string]interface{}) models.Node {
+	return models.Node{ID: id, Type: nodeType, Data: models.NodeData{Config: config}}
+}
+
+func replyNode(id, content string) models.Node {
+	return loopNode(id, "discordReply", map[string]interface{}{"content": content})
+}
+
+func loopEdge(source, target, handle string) models.Edge {
+	return models.Edge{ID: source + "-" + target, Source: source, Target: target, SourceHandle: handle}
+}
+
+// runLoopFlow スタートノードからループノードへ進むフローを実行し、送信されたメッセージを返します
+func runLoopFlow(t *testing.T, content string, loop models.Node, nodes []models.Node, edges []models.Edge) ([]string, *bot.ExecutionContext, error) {
+	t.Helper()
+	flow := models.FlowData{
+		Key:   "test",
+		Nodes: append([]models.Node{loopNode("start", bot.TriggerMention, nil), loop}, nodes...),
+		Edges: append([]models.Edge{loopEdge("start", loop.ID, "")}, edges...),
+	}
+	trigger := &bot.Trigger{
+		Type:      bot.TriggerMention,
+		ID:        "m1",
+		ChannelID: "c1",
+		Content:   content,
+		Author:    &discordgo.User{ID: "u1", Username: "alice"},
+	}
+	client := bot.NewFakeClient("bot")
+	exec, err := newLoopTestExecutor().ExecuteFlow(context.Background(), flow, trigger, client)
+
+	var messages []string
+	for _, action := range client.Actions() {
+		if action.Type == "sendMessage" {
+			messages = append(messages, action.Content)
+		}
+	}
+	return messages, exec, err
+}
+
+func TestForEachNode(t *testing.T) {
+	loop := loopNode("loop", "forEach", map[string]interface{}{"list": "trigger.content"})
+	messages, exec, err := runLoopFlow(t, "a\n\nb\nc", loop,
+		[]models.Node{replyNode("body", "{{.index}}:{{.item}}"), replyNode("done", "done")},
+		[]models.Edge{loopEdge("loop", "body", "body"), loopEdge("loop", "done", "done")},
+	)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	// 空行は要素に含めない
+	if want := []string{"0:a", "1:b", "2:c", "done"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+	if result, _ := exec.Result("loop"); result.Output["count"] != 3 {
+		t.Errorf("count = %v, want 3", result.Output["count"])
+	}
+}
+
+func TestForEachNodeVariableNames(t *testing.T) {
+	loop := loopNode("loop", "forEach", map[string]interface{}{
+		"list":          "trigger.content",
+		"itemVariable":  "line",
+		"indexVariable": "n",
+	})
+	messages, _, err := runLoopFlow(t, "x\ny", loop,
+		[]models.Node{replyNode("body", "{{.n}}={{.line}}")},
+		[]models.Edge{loopEdge("loop", "body", "body")},
+	)
+	if err != nil {
+		t.Fatalf("ExecuteFlow: %v", err)
+	}
+	if want := []string{"0=x", "1=y"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
+
+func TestForEachNodeIterationLimit(t *testing.T) {
+	loop := loopNode("loop", "forEach", map[string]interface{}{"list": "trigger.content", "maxIterations": 2})
+	messages, _, err := runLoopFlow(t, "a\nb\nc", loop,
+		[]models.Node{replyNode("body", "{{.item}}")},
+		[]models.Edge{loopEdge("loop", "body", "body")},
+	)
+	if err == nil || !strings.Contains(err.Error(), "最大反復回数 2") {
+		t.Fatalf("err = %v, want the iteration limit error", err)
+	}
+	// 上限を超える場合は1回も実行しない
+	if len(messages) != 0 {
+		t.Errorf("messages = %q, want none", messages)
+	}
+}
+
+func TestWhileNode(t *testing.T) {
+	tests := []struct {
+		name       string
+		condition  string
+		want       []string
+		iterations int
+	}{
+		{"repeats while true", "index < 3", []string{"0", "1", "2", "done"}, 3},
+		{"false on entry", "index < 0", []string{"done"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loop := loopNode("loop", "while", map[string]interface{}{"condition": tt.condition})
+			messages, exec, err := runLoopFlow(t, "", loop,
+				[]models.Node{replyNode("body", "{{.index}}"), replyNode("done", "done")},
+				[]models.Edge{loopEdge("loop", "body", "body"), loopEdge("loop", "done", "done")},
+			)
+			if err != nil {
+				t.Fatalf("ExecuteFlow: %v", err)
+			}
+			if !reflect.DeepEqual(messages, tt.want) {
+				t.Errorf("messages = %q, want %q", messages, tt.want)
+			}
+			if result, _ := exec.Result("loop"); result.Output["iterations"] != tt.iterations {
+				t.Errorf("iterations = %v, want %d", result.Output["iterations"], tt.iterations)
+			}
+		})
+	}
+}
+
+func TestWhileNodeIterationLimit(t *testing.T) {
+	loop := loopNode("loop", "while", map[string]interface{}{"condition": "true", "maxIterations": 3})
+	messages, _, err := runLoopFlow(t, "", loop,
+		[]models.Node{replyNode("body", "{{.index}}"), replyNode("done", "done")},
+		[]models.Edge{loopEdge("loop", "body", "body"), loopEdge("loop", "done", "done")},
+	)
+	if err == nil || !strings.Contains(err.Error(), "最大反復回数 3") {
+		t.Fatalf("err = %v, want the iteration limit error", err)
+	}
+	if want := []string{"0", "1", "2"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
+
+func TestRetryNode(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		body     []models.Node
+		want     []string
+		attempts int
+	}{
+		{
+			name:     "gives up after max attempts",
+			config:   map[string]interface{}{"maxAttempts": 3},
+			body:     []models.Node{replyNode("body", "try {{.attempt}}"), loopNode("next", "fail", nil)},
+			want:     []string{"try 1", "try 2", "try 3", "failed"},
+			attempts: 3,
+		},
+		{
+			name:     "until condition",
+			config:   map[string]interface{}{"maxAttempts": 5, "until": "attempt >= 2"},
+			body:     []models.Node{replyNode("body", "try {{.attempt}}"), replyNode("next", "ok")},
+			want:     []string{"try 1", "ok", "try 2", "ok", "done"},
+			attempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loop := loopNode("retry", "retry", tt.config)
+			nodes := append(tt.body, replyNode("done", "done"), replyNode("failed", "failed"))
+			messages, exec, err := runLoopFlow(t, "", loop, nodes, []models.Edge{
+				loopEdge("retry", "body", "body"),
+				loopEdge("body", "next", ""),
+				loopEdge("retry", "done", "done"),
+				loopEdge("retry", "failed", "failed"),
+			})
+			if err != nil {
+				t.Fatalf("ExecuteFlow: %v", err)
+			}
+			if !reflect.DeepEqual(messages, tt.want) {
+				t.Errorf("messages = %q, want %q", messages, tt.want)
+			}
+			if result, _ := exec.Result("retry"); result.Output["attempts"] != tt.attempts {
+				t.Errorf("attempts = %v, want %d", result.Output["attempts"], tt.attempts)
+			}
+		})
+	}
+}
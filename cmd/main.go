@@ -6,12 +6,12 @@ import (
 	"log"
 	"regexp"
 	"strings"
-	"time"
 
 	"discord-bot-service/bot"
 	"discord-bot-service/dify"
 	"discord-bot-service/internal/api"
 	"discord-bot-service/internal/config"
+	"discord-bot-service/internal/models"
 	"discord-bot-service/internal/repository/mongodb"
 	"discord-bot-service/internal/service"
 	"discord-bot-service/pkg/database"
@@ -21,8 +21,8 @@ import (
 )
 
 var (
-	conversationIds = make(map[string]string)
-	nodeService     *service.NodeDifyService
+	nodeService         *service.NodeDifyService
+	conversationService *service.DifyConversationService
 )
 
 func main() {
@@ -41,28 +41,44 @@ func main() {
 
 	executor := bot.NewFlowExecutor()
 
-	executor.RegisterNodeExecutor("start", startNodeExecutor)
-	executor.RegisterNodeExecutor("server", serverNodeExecutor)
-	executor.RegisterNodeExecutor("channel", channelNodeExecutor)
-	executor.RegisterNodeExecutor("dify", difyNodeExecutor)
-	executor.RegisterNodeExecutor("discordReply", discordReplyNodeExecutor)
-
 	// Initialize repository
 	db := client.Database(cfg.MongoDBName)
 	repo := mongodb.NewRepository(db)
+	if err := repo.FlowRun.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Failed to create flow run indexes: %v", err)
+	}
+	if err := repo.BotFlow.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Failed to create bot flow indexes: %v", err)
+	}
+	if err := repo.Versions.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Failed to create flow version indexes: %v", err)
+	}
+	if err := repo.Conversations.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Failed to create dify conversation indexes: %v", err)
+	}
 
 	// Initialize service
-	flowService := service.NewFlowDataService(repo)
+	flowService := service.NewFlowDataService(repo, executor)
+	executor.Flows = flowService
 	botService := service.NewBotService(repo)
+	runService := service.NewFlowRunService(repo)
+	botFlowService := service.NewBotFlowService(repo, flowService)
 	nodeService = service.NewNodeDifyService(repo)
+	conversationService = service.NewDifyConversationService(repo)
+	registerNodeExecutors(executor, newDifyValidator(nodeService))
+
+	botManager := bot.NewBotManager(botFlowService, runService, executor, cfg.MessageServiceURL)
 
 	// Setup Gin router
 	router := gin.Default()
 
 	// Setup routes
-	api.SetupFlowDataRoutes(router, flowService)
-	api.SetupBotRoutes(router, botService, flowService, executor, cfg.MessageServiceURL)
+	api.SetupFlowDataRoutes(router, flowService, executor, botManager)
+	api.SetupBotRoutes(router, botService, botFlowService, botManager)
 	api.SetupNodeRoutes(router, nodeService)
+	api.SetupDifyConversationRoutes(router, conversationService)
+	api.SetupRunRoutes(router, runService)
+	api.SetupNodeTypeRoutes(router, executor)
 	// Start server
 	log.Printf("Starting server on %s", cfg.ServerAddress)
 	if err := router.Run(cfg.ServerAddress); err != nil {
@@ -84,56 +100,227 @@ func startNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
 		Continue: true,
 	}, nil
 }
+
+// serverNodeConfig serverノードの設定
+type serverNodeConfig struct {
+	GuildIDs []string `json:"guildIds"`
+}
+
+var serverNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"guildIds": bot.StringListSchema("通過させるサーバーID"),
+})
+
+// serverNodeExecutor トリガーが設定されたサーバーで発生した場合のみ後続へ進みます
+// guildIds が未設定の古いノードはノードIDに埋め込まれたサーバーIDで判定します
 func serverNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config serverNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+	matched := props.Client.BotUserID()+"-"+props.Trigger.GuildID == props.Node.ID
+	if len(config.GuildIDs) > 0 {
+		matched = containsString(config.GuildIDs, props.Trigger.GuildID)
+	}
 	return bot.NodeResult{
 		Type:     "server",
-		Continue: props.Session.State.User.ID+"-"+props.Message.GuildID == props.Node.ID,
+		Continue: matched,
 	}, nil
 }
+
+// channelNodeConfig channelノードの設定
+type channelNodeConfig struct {
+	ChannelIDs []string `json:"channelIds"`
+}
+
+var channelNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"channelIds": bot.StringListSchema("通過させるチャンネルID"),
+})
+
+// channelNodeExecutor トリガーが設定されたチャンネルで発生した場合のみ後続へ進みます
+// channelIds が未設定の古いノードはノードIDに埋め込まれたチャンネルIDで判定します
 func channelNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config channelNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+	matched := props.Client.BotUserID()+"-"+props.Trigger.ChannelID == props.Node.ID
+	if len(config.ChannelIDs) > 0 {
+		matched = containsString(config.ChannelIDs, props.Trigger.ChannelID)
+	}
 	return bot.NodeResult{
 		Type:     "channel",
-		Continue: props.Session.State.User.ID+"-"+props.Message.ChannelID == props.Node.ID,
+		Continue: matched,
 	}, nil
 }
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// difyNodeConfig difyノードの設定
+type difyNodeConfig struct {
+	// App 呼び出すDifyアプリ（NodeDifyの名前）
+	App string `json:"app"`
+	// Reply 回答の送信先
+	Reply bot.ReplyOptions `json:"reply"`
+	// Streaming 回答を生成しながらメッセージを編集して表示する
+	Streaming bool `json:"streaming"`
+	// Conversation 会話を共有する単位。空の場合は channel
+	Conversation string `json:"conversation"`
+	// Inputs Difyアプリの入力変数名と、値を描画するテンプレート
+	Inputs map[string]string `json:"inputs"`
+}
+
+// 会話を共有する単位
+const (
+	conversationChannel     = "channel"
+	conversationUser        = "user"
+	conversationUserChannel = "userChannel"
+	conversationThread      = "thread"
+	conversationStateless   = "stateless"
+)
+
+// conversationScope 会話を保存するキーを返します。stateless の場合は空文字
+// channelID は回答を送信するチャンネル（スレッド）です
+// スレッドのIDはチャンネルIDでもあるため、thread はチャンネル単位と同じキーになり、同じ会話を共有します
+func (c difyNodeConfig) conversationScope(trigger *bot.Trigger, channelID string) string {
+	userID := difyUser(trigger)
+	switch c.Conversation {
+	case conversationUser:
+		return "user:" + userID
+	case conversationUserChannel:
+		return "channel:" + channelID + ":user:" + userID
+	case conversationStateless:
+		return ""
+	}
+	return "channel:" + channelID
+}
+
+// difyUser Difyへ送るユーザーID。ユーザーのいないトリガーでは共通の値を使います
+func difyUser(trigger *bot.Trigger) string {
+	if trigger.Author == nil {
+		return "user"
+	}
+	return trigger.Author.ID
+}
+
+var difyNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"app":   {Type: "string", Title: "Difyアプリ", Description: "登録済みのDifyアプリ名。未設定の場合はラベルを使用します"},
+	"reply": bot.ReplySchema,
+	"streaming": {
+		Type:        "boolean",
+		Title:       "ストリーミング",
+		Description: "回答を生成しながら表示します",
+	},
+	"conversation": {
+		Type:        "string",
+		Title:       "会話の単位",
+		Description: "channel: チャンネル（スレッド内ではスレッド）で共有、user: ユーザーごと、userChannel: チャンネル内のユーザーごと、thread: channel と同じ、stateless: 会話を続けない",
+		Enum:        []interface{}{conversationChannel, conversationUser, conversationUserChannel, conversationThread, conversationStateless},
+		Default:     conversationChannel,
+	},
+	"inputs": {
+		Type:                 "object",
+		Title:                "入力変数",
+		Description:          "Difyアプリの入力変数ごとに {{.trigger.channelId}} のようなテンプレートを指定します",
+		AdditionalProperties: templateSchema("値"),
+	},
+})
+
+// appName 呼び出すDifyアプリ名を返します
+// app が未設定の古いノードはラベルをアプリ名として扱います
+func (c difyNodeConfig) appName(node models.Node) string {
+	if c.App != "" {
+		return c.App
+	}
+	return node.Data.Label
+}
+
+// renderInputs 入力変数のテンプレートを描画します
+func (c difyNodeConfig) renderInputs(vars *bot.Variables) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{}, len(c.Inputs))
+	for name, source := range c.Inputs {
+		value, err := bot.RenderTemplate(source, vars)
+		if err != nil {
+			return nil, fmt.Errorf("入力変数 %s: %w", name, err)
+		}
+		inputs[name] = strings.TrimSpace(value)
+	}
+	return inputs, nil
+}
+
 func difyNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-	botConfig, err := nodeService.GetNodeDifyByName(ctx, props.Node.Data.Label)
-	if err != nil {
-		return bot.NodeResult{
-			Type:     "dify",
-			Continue: true,
-		}, nil
+	var config difyNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+	app := config.appName(props.Node)
 
+	botConfig, err := nodeService.GetNodeDifyByName(props.Context, app)
+	if err != nil {
+		return bot.NodeResult{Type: "dify"}, fmt.Errorf("Difyアプリ %s を取得できません: %w", app, err)
 	}
 
-	cleanContent := strings.ReplaceAll(props.Message.Content, "<@"+props.Session.State.User.ID+">", "")
+	cleanContent := strings.ReplaceAll(props.Trigger.Content, "<@"+props.Client.BotUserID()+">", "")
 	cleanContent = strings.TrimSpace(cleanContent)
-	conversationId := conversationIds[props.Node.Data.Label+props.Message.ChannelID]
-	props.Session.ChannelTyping(props.Message.ChannelID)
-	response, err := dify.GenerateMessage(botConfig.Url, botConfig.Token, conversationId, props.Message.ChannelID+"zzxxxMxxzz"+cleanContent)
+	target, err := bot.ResolveReplyTarget(props, config.Reply, cleanContent)
+	if err != nil {
+		return bot.NodeResult{Type: "dify"}, err
+	}
+	inputs, err := config.renderInputs(props.Exec.Variables)
 	if err != nil {
-		SendMessage(props.Session, props.Message.ChannelID, err.Error())
-		return bot.NodeResult{
-			Type:     "dify",
-			Continue: false,
-		}, nil
+		return bot.NodeResult{Type: "dify"}, err
 	}
-	conversationIds[props.Node.Data.Label+props.Message.ChannelID] = response.ConversationID
+	user := difyUser(props.Trigger)
 
-	SendMessage(props.Session, props.Message.ChannelID, addDomain(botConfig.Url, response.Answer))
+	var result *difyResult
+	simulated := bot.IsSimulation(props.Context)
+	switch {
+	case simulated:
+		result = simulateDify(botConfig, cleanContent)
+	case botConfig.AppType == models.DifyAppCompletion:
+		result, err = runDifyCompletion(props, config, target, botConfig, dify.RunRequest{Inputs: completionInputs(inputs, cleanContent), User: user})
+	case botConfig.AppType == models.DifyAppWorkflow:
+		result, err = runDifyWorkflow(props, config, target, botConfig, dify.RunRequest{Inputs: inputs, User: user})
+	default:
+		result, err = runDifyChat(props, config, target, botConfig, dify.RequestBody{Inputs: inputs, Query: cleanContent, User: user})
+	}
+	if err != nil {
+		// エラーの通知はフローの error ハンドルに任せる
+		return bot.NodeResult{Type: "dify"}, err
+	}
+
+	answer := addDomain(botConfig.Url, result.answer)
+	// ストリーミングの場合は受信しながら送信済み
+	if (!config.Streaming || simulated) && strings.TrimSpace(answer) != "" {
+		if err := sendReply(props.Client, target, answer); err != nil {
+			return bot.NodeResult{Type: "dify"}, err
+		}
+	}
+
+	// 後続ノードから回答を参照できるようにする
+	output := map[string]interface{}{"answer": answer}
+	props.Exec.Variables.Set("dify.answer", answer)
+	if result.conversationID != "" {
+		props.Exec.Variables.Set("dify.conversationId", result.conversationID)
+		output["conversationId"] = result.conversationID
+	}
+	if result.outputs != nil {
+		for name, value := range result.outputs {
+			props.Exec.Variables.Set("dify.outputs."+name, value)
+		}
+		output["outputs"] = result.outputs
+	}
 
 	return bot.NodeResult{
 		Type:     "dify",
 		Continue: true,
-	}, nil
-}
-func discordReplyNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
-	// エンドノードのロジックを実装
-	return bot.NodeResult{
-		Type:     "Rep",
-		Continue: true,
+		Output:   output,
 	}, nil
 }
 
@@ -167,13 +354,17 @@ func SplitMessage(message string) []string {
 	return chunks
 }
 
-// Function to send a message and split it if necessary
-func SendMessage(session *discordgo.Session, channelID, message string) {
-	chunks := SplitMessage(message)
-	for _, chunk := range chunks {
-		_, err := session.ChannelMessageSend(channelID, chunk)
-		if err != nil {
-			fmt.Println("Error sending message:", err)
+// sendReply 送信先に従ってメッセージを分割して送信します
+// 返信の参照は最初のメッセージにのみ付けます
+func sendReply(client bot.DiscordClient, target bot.ReplyTarget, message string) error {
+	for i, chunk := range SplitMessage(message) {
+		data := &discordgo.MessageSend{Content: chunk}
+		if i == 0 {
+			target.Apply(data)
+		}
+		if _, err := client.SendComplex(target.ChannelID, data); err != nil {
+			return err
 		}
 	}
+	return nil
 }
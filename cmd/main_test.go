@@ -0,0 +1,38 @@
+package main
+
+import (
+	"discord-bot-service/bot"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestConversationScope(t *testing.T) {
+	trigger := &bot.Trigger{ChannelID: "c1", Author: &discordgo.User{ID: "u1"}}
+	tests := []struct {
+		conversation string
+		channelID    string
+		want         string
+	}{
+		{"", "c1", "channel:c1"},
+		{conversationChannel, "c1", "channel:c1"},
+		// スレッド単位はチャンネル単位と同じ会話を使う
+		{conversationThread, "c1", "channel:c1"},
+		{conversationThread, "thread1", "channel:thread1"},
+		{conversationUser, "c1", "user:u1"},
+		{conversationUserChannel, "c1", "channel:c1:user:u1"},
+		{conversationStateless, "c1", ""},
+	}
+	for _, tt := range tests {
+		config := difyNodeConfig{Conversation: tt.conversation}
+		if got := config.conversationScope(trigger, tt.channelID); got != tt.want {
+			t.Errorf("conversationScope(%q, %q) = %q, want %q", tt.conversation, tt.channelID, got, tt.want)
+		}
+	}
+
+	// ユーザーのいないトリガーでは共通のユーザーとして扱う
+	config := difyNodeConfig{Conversation: conversationUser}
+	if got := config.conversationScope(&bot.Trigger{ChannelID: "c1"}, "c1"); got != "user:user" {
+		t.Errorf("without author: conversationScope = %q, want user:user", got)
+	}
+}
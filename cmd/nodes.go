@@ -0,0 +1,147 @@
+package main
+
+import "discord-bot-service/bot"
+
+// registerNodeExecutors フローで使用できるノードタイプを登録します
+// ここで登録したノードは GET /node-types を通じてエディタのパレットに表示されます
+func registerNodeExecutors(executor *bot.FlowExecutor, difyValidator *difyValidator) {
+	// トリガー
+	executor.RegisterNodeExecutor(bot.TriggerMention, startNodeExecutor,
+		bot.WithTriggerMatcher(nil),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "メンション", Description: "ボットがメンションされたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerSlashCommand, bot.SlashCommandNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchSlashCommand),
+		bot.WithConfigSchema(bot.SlashCommandSchema),
+		bot.WithValidator(bot.ValidateSlashCommandNode),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "スラッシュコマンド", Description: "スラッシュコマンドが実行されたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerMessageCreate, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchMessageTrigger),
+		bot.WithConfigSchema(bot.MessageTriggerSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "メッセージ投稿", Description: "サーバーのチャンネルにメッセージが投稿されたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerDirectMessage, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchMessageTrigger),
+		bot.WithConfigSchema(bot.MessageTriggerSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "DM", Description: "ボットにDMが届いたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerMessageUpdate, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchMessageTrigger),
+		bot.WithConfigSchema(bot.MessageTriggerSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "メッセージ編集", Description: "メッセージが編集されたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerKeyword, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchKeywordTrigger),
+		bot.WithConfigSchema(bot.KeywordTriggerSchema),
+		bot.WithValidator(bot.ValidateKeywordTriggerNode),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "キーワード", Description: "キーワードを含むメッセージが投稿されたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerReactionAdd, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchReactionTrigger),
+		bot.WithConfigSchema(bot.ReactionTriggerSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "リアクション", Description: "メッセージにリアクションが付いたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerMemberJoin, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchMemberJoinTrigger),
+		bot.WithConfigSchema(bot.MemberJoinTriggerSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "メンバー参加", Description: "サーバーにメンバーが参加したときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerThreadCreate, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(bot.MatchThreadCreateTrigger),
+		bot.WithConfigSchema(bot.ThreadCreateTriggerSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "スレッド作成", Description: "スレッドが作成されたときに開始します"}))
+	executor.RegisterNodeExecutor(bot.TriggerSubflow, bot.TriggerNodeExecutor,
+		bot.WithTriggerMatcher(nil),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "サブフロー開始", Description: "subflow ノードから呼び出されたときに開始します"}))
+
+	// 絞り込み
+	executor.RegisterNodeExecutor("server", serverNodeExecutor,
+		bot.WithConfigSchema(serverNodeSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "サーバー", Category: bot.CategoryFilter, Description: "指定したサーバーの場合のみ後続へ進みます"}))
+	executor.RegisterNodeExecutor("channel", channelNodeExecutor,
+		bot.WithConfigSchema(channelNodeSchema),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "チャンネル", Category: bot.CategoryFilter, Description: "指定したチャンネルの場合のみ後続へ進みます"}))
+
+	// 制御
+	executor.RegisterNodeExecutor("if", ifNodeExecutor,
+		bot.WithConfigSchema(ifNodeSchema),
+		bot.WithValidator(validateIfNode),
+		bot.WithMetadata(bot.NodeMetadata{
+			DisplayName: "条件分岐",
+			Category:    bot.CategoryLogic,
+			Outputs:     []bot.NodeHandle{{ID: "true", Label: "真"}, {ID: "false", Label: "偽"}},
+		}))
+	executor.RegisterNodeExecutor("switch", switchNodeExecutor,
+		bot.WithConfigSchema(switchNodeSchema),
+		bot.WithValidator(validateSwitchNode),
+		bot.WithMetadata(bot.NodeMetadata{
+			DisplayName:    "多分岐",
+			Category:       bot.CategoryLogic,
+			Outputs:        []bot.NodeHandle{{ID: "default", Label: "該当なし"}},
+			DynamicOutputs: true,
+		}))
+
+	executor.RegisterNodeExecutor(bot.NodeTypeJoin, bot.JoinNodeExecutor,
+		bot.WithJoin(),
+		bot.WithConfigSchema(bot.JoinNodeSchema),
+		bot.WithMetadata(bot.NodeMetadata{
+			DisplayName: "合流",
+			Category:    bot.CategoryLogic,
+			Description: "複数の分岐を待ち合わせ、それぞれの出力を変数にまとめます",
+		}))
+
+	executor.RegisterNodeExecutor("forEach", forEachNodeExecutor,
+		bot.WithoutDefaultTimeout(),
+		bot.WithConfigSchema(forEachNodeSchema),
+		bot.WithMetadata(bot.NodeMetadata{
+			DisplayName: "繰り返し（リスト）",
+			Category:    bot.CategoryLogic,
+			Description: "リストの要素ごとに body の先を実行します",
+			Outputs:     loopOutputs,
+		}))
+	executor.RegisterNodeExecutor("while", whileNodeExecutor,
+		bot.WithoutDefaultTimeout(),
+		bot.WithConfigSchema(whileNodeSchema),
+		bot.WithValidator(validateWhileNode),
+		bot.WithMetadata(bot.NodeMetadata{
+			DisplayName: "繰り返し（条件）",
+			Category:    bot.CategoryLogic,
+			Description: "条件を満たす間 body の先を実行します",
+			Outputs:     loopOutputs,
+		}))
+	executor.RegisterNodeExecutor("retry", retryNodeExecutor,
+		bot.WithoutDefaultTimeout(),
+		bot.WithConfigSchema(retryNodeSchema),
+		bot.WithValidator(validateRetryNode),
+		bot.WithMetadata(bot.NodeMetadata{
+			DisplayName: "再試行",
+			Category:    bot.CategoryLogic,
+			Description: "body の先が成功するまで再試行します",
+			Outputs: []bot.NodeHandle{
+				{ID: "body", Label: "本体"},
+				{ID: "done", Label: "成功"},
+				{ID: "failed", Label: "失敗"},
+			},
+		}))
+
+	executor.RegisterNodeExecutor(bot.NodeTypeSubflow, bot.SubflowNodeExecutor,
+		bot.WithoutDefaultTimeout(),
+		bot.WithConfigSchema(bot.SubflowNodeSchema),
+		bot.WithValidator(bot.ValidateSubflowNode),
+		bot.WithMetadata(bot.NodeMetadata{
+			DisplayName: "サブフロー",
+			Category:    bot.CategoryLogic,
+			Description: "別のフローを呼び出し、結果を変数で受け取ります",
+		}))
+
+	// AI
+	executor.RegisterNodeExecutor("dify", difyNodeExecutor,
+		bot.WithConfigSchema(difyNodeSchema),
+		bot.WithValidator(difyValidator.validateNode),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "Dify", Category: bot.CategoryAI, Description: "Difyアプリに問い合わせて回答を投稿します"}))
+
+	// Discord
+	executor.RegisterNodeExecutor("discordReply", discordReplyNodeExecutor,
+		bot.WithConfigSchema(discordReplyNodeSchema),
+		bot.WithValidator(validateDiscordReplyNode),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "返信", Category: bot.CategoryDiscord, Description: "トリガーのチャンネルへメッセージを送信します"}))
+	executor.RegisterNodeExecutor("discordEmbed", discordEmbedNodeExecutor,
+		bot.WithConfigSchema(discordEmbedNodeSchema),
+		bot.WithValidator(validateDiscordEmbedNode),
+		bot.WithMetadata(bot.NodeMetadata{DisplayName: "埋め込み返信", Category: bot.CategoryDiscord, Description: "トリガーのチャンネルへ埋め込みを送信します"}))
+}
+
+var loopOutputs = []bot.NodeHandle{{ID: "body", Label: "本体"}, {ID: "done", Label: "完了"}}
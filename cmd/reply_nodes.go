@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"discord-bot-service/bot"
+	"discord-bot-service/internal/models"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discordReplyNodeConfig discordReplyノードの設定
+type discordReplyNodeConfig struct {
+	// Content 送信する本文のテンプレート。描画結果が空の場合は何も送信しません
+	Content string           `json:"content"`
+	Reply   bot.ReplyOptions `json:"reply"`
+}
+
+var discordReplyNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"reply": bot.ReplySchema,
+	"content": {
+		Type:        "string",
+		Title:       "本文",
+		Description: "{{.trigger.authorName}} や {{.dify.answer | truncate 1500}} のように変数を埋め込めます",
+		Format:      "template",
+	},
+})
+
+// discordReplyNodeExecutor テンプレートを描画し、設定された送信先へ送信します
+func discordReplyNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config discordReplyNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+	content, err := bot.RenderTemplate(config.Content, props.Exec.Variables)
+	if err != nil {
+		return bot.NodeResult{}, err
+	}
+	if strings.TrimSpace(content) != "" {
+		target, err := bot.ResolveReplyTarget(props, config.Reply, content)
+		if err != nil {
+			return bot.NodeResult{}, err
+		}
+		if err := sendReply(props.Client, target, content); err != nil {
+			return bot.NodeResult{}, err
+		}
+	}
+	return bot.NodeResult{
+		Type:     "Rep",
+		Continue: true,
+		Output:   map[string]interface{}{"content": content},
+	}, nil
+}
+
+func validateDiscordReplyNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config discordReplyNodeConfig
+	if err := bot.DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+	errs := bot.ValidateTemplateField("config.content", config.Content)
+	return append(errs, bot.ValidateReplyOptions("config.reply", config.Reply)...)
+}
+
+// discordEmbedNodeConfig discordEmbedノードの設定
+// 文字列の項目はすべてテンプレートとして描画されます
+type discordEmbedNodeConfig struct {
+	Content string           `json:"content"`
+	Embeds  []embedConfig    `json:"embeds"`
+	Reply   bot.ReplyOptions `json:"reply"`
+}
+
+type embedConfig struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	// Color "#5865F2" のような16進数、または10進数の色
+	Color     string             `json:"color"`
+	Fields    []embedFieldConfig `json:"fields"`
+	Thumbnail string             `json:"thumbnail"`
+	Image     string             `json:"image"`
+	Footer    embedFooterConfig  `json:"footer"`
+	Author    embedAuthorConfig  `json:"author"`
+}
+
+type embedFieldConfig struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type embedFooterConfig struct {
+	Text    string `json:"text"`
+	IconURL string `json:"iconUrl"`
+}
+
+type embedAuthorConfig struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	IconURL string `json:"iconUrl"`
+}
+
+func templateSchema(title string) *bot.Schema {
+	return &bot.Schema{Type: "string", Title: title, Format: "template"}
+}
+
+var discordEmbedNodeSchema = bot.ObjectSchema(map[string]*bot.Schema{
+	"content": templateSchema("本文"),
+	"reply":   bot.ReplySchema,
+	"embeds": {
+		Type:     "array",
+		Title:    "埋め込み",
+		MaxItems: bot.IntPtr(bot.MaxEmbedsPerMessage),
+		Items: bot.ObjectSchema(map[string]*bot.Schema{
+			"title":       templateSchema("タイトル"),
+			"description": templateSchema("説明"),
+			"url":         templateSchema("URL"),
+			"color":       templateSchema("色"),
+			"fields": {
+				Type:     "array",
+				Title:    "フィールド",
+				MaxItems: bot.IntPtr(bot.MaxEmbedFields),
+				Items: bot.ObjectSchema(map[string]*bot.Schema{
+					"name":   templateSchema("名前"),
+					"value":  templateSchema("値"),
+					"inline": {Type: "boolean", Title: "横に並べる"},
+				}, "name", "value"),
+			},
+			"thumbnail": templateSchema("サムネイルURL"),
+			"image":     templateSchema("画像URL"),
+			"footer": bot.ObjectSchema(map[string]*bot.Schema{
+				"text":    templateSchema("フッター"),
+				"iconUrl": templateSchema("フッターのアイコンURL"),
+			}),
+			"author": bot.ObjectSchema(map[string]*bot.Schema{
+				"name":    templateSchema("作成者"),
+				"url":     templateSchema("作成者のURL"),
+				"iconUrl": templateSchema("作成者のアイコンURL"),
+			}),
+		}),
+	},
+}, "embeds")
+
+// discordEmbedNodeExecutor 埋め込みを描画し、上限を確認してから設定された送信先へ送信します
+func discordEmbedNodeExecutor(props bot.NodeProps) (bot.NodeResult, error) {
+	var config discordEmbedNodeConfig
+	if err := bot.DecodeNodeConfig(props.Node, &config); err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	r := &templateRenderer{vars: props.Exec.Variables}
+	message := &discordgo.MessageSend{Content: r.render(config.Content)}
+	for _, c := range config.Embeds {
+		message.Embeds = append(message.Embeds, c.build(r))
+	}
+	if r.err != nil {
+		return bot.NodeResult{}, r.err
+	}
+	// 埋め込みと同じメッセージで送るため、discordReply のように本文を分割できない
+	if err := bot.ValidateMessageContent(message.Content); err != nil {
+		return bot.NodeResult{}, err
+	}
+	if err := bot.ValidateEmbeds(message.Embeds); err != nil {
+		return bot.NodeResult{}, err
+	}
+
+	target, err := bot.ResolveReplyTarget(props, config.Reply, message.Content)
+	if err != nil {
+		return bot.NodeResult{}, err
+	}
+	target.Apply(message)
+	sent, err := props.Client.SendComplex(target.ChannelID, message)
+	if err != nil {
+		return bot.NodeResult{}, err
+	}
+	return bot.NodeResult{
+		Type:     "discordEmbed",
+		Continue: true,
+		Output:   map[string]interface{}{"messageId": sent.ID},
+	}, nil
+}
+
+// build 設定を描画して埋め込みを作成します
+func (c embedConfig) build(r *templateRenderer) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       r.render(c.Title),
+		Description: r.render(c.Description),
+		URL:         r.render(c.URL),
+	}
+	if color := r.render(c.Color); color != "" {
+		value, err := parseColor(color)
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+		embed.Color = value
+	}
+	for _, f := range c.Fields {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   r.render(f.Name),
+			Value:  r.render(f.Value),
+			Inline: f.Inline,
+		})
+	}
+	if url := r.render(c.Thumbnail); url != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: url}
+	}
+	if url := r.render(c.Image); url != "" {
+		embed.Image = &discordgo.MessageEmbedImage{URL: url}
+	}
+	if text := r.render(c.Footer.Text); text != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: text, IconURL: r.render(c.Footer.IconURL)}
+	}
+	if name := r.render(c.Author.Name); name != "" {
+		embed.Author = &discordgo.MessageEmbedAuthor{Name: name, URL: r.render(c.Author.URL), IconURL: r.render(c.Author.IconURL)}
+	}
+	return embed
+}
+
+// templateRenderer 複数のテンプレートを描画し、最初のエラーを保持します
+type templateRenderer struct {
+	vars *bot.Variables
+	err  error
+}
+
+func (r *templateRenderer) render(source string) string {
+	if source == "" || r.err != nil {
+		return ""
+	}
+	out, err := bot.RenderTemplate(source, r.vars)
+	if err != nil {
+		r.err = err
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// parseColor "#RRGGBB"、"0xRRGGBB"、または10進数の色を数値に変換します
+func parseColor(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	var (
+		value int64
+		err   error
+	)
+	switch {
+	case strings.HasPrefix(s, "#"):
+		value, err = strconv.ParseInt(s[1:], 16, 32)
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		value, err = strconv.ParseInt(s[2:], 16, 32)
+	default:
+		value, err = strconv.ParseInt(s, 10, 32)
+	}
+	if err != nil || value < 0 || value > 0xFFFFFF {
+		return 0, fmt.Errorf("色 %q を解釈できません", s)
+	}
+	return int(value), nil
+}
+
+func validateDiscordEmbedNode(ctx context.Context, node models.Node) []models.ValidationError {
+	var config discordEmbedNodeConfig
+	if err := bot.DecodeNodeConfig(node, &config); err != nil {
+		return []models.ValidationError{{Field: "config", Message: err.Error()}}
+	}
+
+	errs := bot.ValidateTemplateField("config.content", config.Content)
+	// テンプレートを含まない本文はこの時点で文字数を確認できる
+	if !strings.Contains(config.Content, "{{") {
+		if err := bot.ValidateMessageContent(config.Content); err != nil {
+			errs = append(errs, models.ValidationError{Field: "config.content", Message: err.Error()})
+		}
+	}
+	errs = append(errs, bot.ValidateReplyOptions("config.reply", config.Reply)...)
+	if len(config.Embeds) == 0 {
+		errs = append(errs, models.ValidationError{Field: "config.embeds", Message: "埋め込みを1つ以上指定してください"})
+	}
+	for i, e := range config.Embeds {
+		prefix := fmt.Sprintf("config.embeds[%d]", i)
+		templates := map[string]string{
+			".title":          e.Title,
+			".description":    e.Description,
+			".url":            e.URL,
+			".color":          e.Color,
+			".thumbnail":      e.Thumbnail,
+			".image":          e.Image,
+			".footer.text":    e.Footer.Text,
+			".footer.iconUrl": e.Footer.IconURL,
+			".author.name":    e.Author.Name,
+			".author.url":     e.Author.URL,
+			".author.iconUrl": e.Author.IconURL,
+		}
+		for j, f := range e.Fields {
+			templates[fmt.Sprintf(".fields[%d].name", j)] = f.Name
+			templates[fmt.Sprintf(".fields[%d].value", j)] = f.Value
+		}
+		for _, field := range sortedKeys(templates) {
+			errs = append(errs, bot.ValidateTemplateField(prefix+field, templates[field])...)
+		}
+		if e.Title == "" && e.Description == "" && len(e.Fields) == 0 && e.Thumbnail == "" && e.Image == "" &&
+			e.Footer.Text == "" && e.Author.Name == "" {
+			errs = append(errs, models.ValidationError{Field: prefix, Message: "タイトル、説明、フィールドなど表示する内容を指定してください"})
+		}
+		// テンプレートを含まない色はこの時点で解釈できる
+		if e.Color != "" && !strings.Contains(e.Color, "{{") {
+			if _, err := parseColor(e.Color); err != nil {
+				errs = append(errs, models.ValidationError{Field: prefix + ".color", Message: err.Error()})
+			}
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
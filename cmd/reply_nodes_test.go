@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int
+		ok     bool
+	}{
+		{"#5865F2", 0x5865F2, true},
+		{"0x5865f2", 0x5865F2, true},
+		{"0XFFFFFF", 0xFFFFFF, true},
+		{" 255 ", 255, true},
+		{"0", 0, true},
+		{"#GGGGGG", 0, false},
+		{"#", 0, false},
+		{"#1000000", 0, false},
+		{"16777216", 0, false},
+		{"-1", 0, false},
+		{"red", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.source)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseColor(%q) = %d, %v, want %d (ok = %v)", tt.source, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidateDiscordEmbedNode(t *testing.T) {
+	tests := []struct {
+		name   string
+		embeds []interface{}
+		fields []string
+	}{
+		{"valid", []interface{}{map[string]interface{}{"title": "{{.trigger.authorName}}", "color": "#5865F2"}}, nil},
+		{"no embeds", []interface{}{}, []string{"config.embeds"}},
+		{"empty embed", []interface{}{map[string]interface{}{"url": "https://example.com"}}, []string{"config.embeds[0]"}},
+		{"bad color", []interface{}{map[string]interface{}{"title": "t", "color": "#GG0000"}}, []string{"config.embeds[0].color"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := models.Node{ID: "e", Type: "discordEmbed", Data: models.NodeData{Config: map[string]interface{}{"embeds": tt.embeds}}}
+			var fields []string
+			for _, err := range validateDiscordEmbedNode(context.Background(), node) {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("error fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package dify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// RunRequest テキスト生成とワークフローのリクエスト
+// 会話を持たないため、ユーザーの入力も Inputs で渡します
+type RunRequest struct {
+	Inputs       map[string]interface{} `json:"inputs"`
+	ResponseMode string                 `json:"response_mode"`
+	User         string                 `json:"user"`
+}
+
+// GenerateCompletion テキスト生成アプリで文章を生成します
+func GenerateCompletion(ctx context.Context, baseUrl string, token string, body RunRequest) (*ResponseBody, error) {
+	body.ResponseMode = ResponseModeBlocking
+	if body.Inputs == nil {
+		body.Inputs = map[string]interface{}{}
+	}
+
+	resp, err := post(ctx, baseUrl+"/v1/completion-messages", token, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var response ResponseBody
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return &response, nil
+}
+
+// StreamCompletion ストリーミングモードでテキスト生成アプリの文章を生成します
+// 回答の差分が届くたびに onAnswer が呼ばれます
+func StreamCompletion(ctx context.Context, baseUrl string, token string, body RunRequest, onAnswer func(delta string) error) (*ResponseBody, error) {
+	body.ResponseMode = ResponseModeStreaming
+	if body.Inputs == nil {
+		body.Inputs = map[string]interface{}{}
+	}
+
+	resp, err := post(ctx, baseUrl+"/v1/completion-messages", token, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return readAnswer(resp.Body, onAnswer)
+}
@@ -2,18 +2,28 @@ package dify
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 )
 
+// 応答モード
+const (
+	ResponseModeBlocking  = "blocking"
+	ResponseModeStreaming = "streaming"
+)
+
+// RequestBody チャットメッセージのリクエスト
+// ResponseMode は呼び出す関数によって設定されます
 type RequestBody struct {
 	Inputs         map[string]interface{} `json:"inputs"`
 	ConversationID string                 `json:"conversation_id"`
 	Query          string                 `json:"query"`
 	ResponseMode   string                 `json:"response_mode"`
-	User           string                 `json:"user"`
+	// User Dify側でエンドユーザーを識別するID
+	User string `json:"user"`
 }
 
 type ResponseBody struct {
@@ -26,51 +36,62 @@ type ResponseBody struct {
 	Answer         string `json:"answer"`
 }
 
-func GenerateMessage(baseUrl string, token string, conversationId, query string) (*ResponseBody, error) {
-	url := baseUrl + "/v1/chat-messages"
-	method := "POST"
+func GenerateMessage(ctx context.Context, baseUrl string, token string, body RequestBody) (*ResponseBody, error) {
+	body.ResponseMode = ResponseModeBlocking
+	if body.Inputs == nil {
+		body.Inputs = map[string]interface{}{}
+	}
 
-	body := RequestBody{
-		Inputs:         map[string]interface{}{},
-		ConversationID: conversationId,
-		Query:          query,
-		ResponseMode:   "blocking",
-		User:           "user",
+	resp, err := post(ctx, baseUrl+"/v1/chat-messages", token, body)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var response ResponseBody
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return &response, nil
+}
+
+// post JSONのリクエストを送信し、200以外の応答をエラーにします
+// 呼び出し側でレスポンスボディを閉じてください
+func post(ctx context.Context, url, token string, body interface{}) (*http.Response, error) {
 	bodyBytes, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
+	return do(ctx, http.MethodPost, url, token, bytes.NewBuffer(bodyBytes))
+}
 
+func do(ctx context.Context, method, url, token string, body io.Reader) (*http.Response, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Add("Authorization", "Bearer "+token)
-	req.Header.Add("Content-Type", "application/json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("request failed with status: %s, body: %s", resp.Status, string(respBody))
 	}
-
-	var response ResponseBody
-	if err := json.Unmarshal(respBody, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
-	}
-
-	return &response, nil
+	return resp, nil
 }
@@ -0,0 +1,60 @@
+package dify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// InputField アプリの入力フォームの1項目
+type InputField struct {
+	// Type "text-input"、"paragraph"、"select"、"number" など
+	Type     string   `json:"type"`
+	Variable string   `json:"variable"`
+	Label    string   `json:"label"`
+	Required bool     `json:"required"`
+	Default  string   `json:"default"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// Parameters アプリの設定のうち、呼び出し側が必要とするもの
+type Parameters struct {
+	UserInputForm []InputField
+}
+
+// GetParameters アプリの入力フォームなどの設定を取得します
+func GetParameters(ctx context.Context, baseUrl string, token string) (*Parameters, error) {
+	resp, err := do(ctx, http.MethodGet, baseUrl+"/v1/parameters", token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// user_input_form は {"text-input": {...}} のように種類をキーとする要素の配列
+	var body struct {
+		UserInputForm []map[string]InputField `json:"user_input_form"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	params := &Parameters{}
+	for _, item := range body.UserInputForm {
+		for fieldType, field := range item {
+			field.Type = fieldType
+			params.UserInputForm = append(params.UserInputForm, field)
+		}
+	}
+	return params, nil
+}
+
+// Field 変数名に対応する入力項目を返します
+func (p *Parameters) Field(variable string) (InputField, bool) {
+	for _, field := range p.UserInputForm {
+		if field.Variable == variable {
+			return field, true
+		}
+	}
+	return InputField{}, false
+}
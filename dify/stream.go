@@ -0,0 +1,132 @@
+package dify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ストリーミング応答のイベント
+const (
+	EventMessage      = "message"
+	EventAgentMessage = "agent_message"
+	EventMessageEnd   = "message_end"
+	EventError        = "error"
+
+	EventWorkflowStarted  = "workflow_started"
+	EventTextChunk        = "text_chunk"
+	EventWorkflowFinished = "workflow_finished"
+)
+
+// StreamEvent ストリーミング応答（Server-Sent Events）の1イベント
+type StreamEvent struct {
+	Event          string `json:"event"`
+	TaskID         string `json:"task_id"`
+	MessageID      string `json:"message_id"`
+	ConversationID string `json:"conversation_id"`
+	// Answer message / agent_message の場合の回答の差分
+	Answer string `json:"answer"`
+	// Status、Code、Message error の場合の内容
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// WorkflowRunID、Data ワークフローのイベントの場合の内容
+	WorkflowRunID string          `json:"workflow_run_id"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// StreamError ストリームの途中で Dify から返されたエラー
+type StreamError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("dify stream error: status %d, code %s: %s", e.Status, e.Code, e.Message)
+}
+
+// StreamMessage ストリーミングモードでメッセージを生成します
+// 回答の差分が届くたびに onAnswer が呼ばれ、onAnswer がエラーを返すと中断します
+// 戻り値は最後まで受信した回答をまとめたものです
+func StreamMessage(ctx context.Context, baseUrl string, token string, body RequestBody, onAnswer func(delta string) error) (*ResponseBody, error) {
+	body.ResponseMode = ResponseModeStreaming
+	if body.Inputs == nil {
+		body.Inputs = map[string]interface{}{}
+	}
+
+	resp, err := post(ctx, baseUrl+"/v1/chat-messages", token, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return readAnswer(resp.Body, onAnswer)
+}
+
+// readAnswer チャットとテキスト生成のストリーミング応答を読み取ります
+func readAnswer(r io.Reader, onAnswer func(delta string) error) (*ResponseBody, error) {
+	response := &ResponseBody{Event: EventMessageEnd}
+	var answer strings.Builder
+	err := readEvents(r, func(event StreamEvent) error {
+		switch event.Event {
+		case EventMessage, EventAgentMessage:
+			response.TaskID = event.TaskID
+			response.MessageID = event.MessageID
+			response.ConversationID = event.ConversationID
+			if event.Answer == "" {
+				return nil
+			}
+			answer.WriteString(event.Answer)
+			return onAnswer(event.Answer)
+		case EventMessageEnd:
+			response.ConversationID = event.ConversationID
+			return errStreamEnd
+		case EventError:
+			return &StreamError{Status: event.Status, Code: event.Code, Message: event.Message}
+		}
+		// ping などのイベントは無視する
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.ID = response.MessageID
+	response.Answer = answer.String()
+	return response, nil
+}
+
+// errStreamEnd 終了イベントを受信したことを readEvents に伝えるための値
+var errStreamEnd = errors.New("dify stream end")
+
+// readEvents "data: " で始まる行をイベントとして読み取ります
+// handle が errStreamEnd を返すまでにストリームが終わった場合はエラーになります
+func readEvents(r io.Reader, handle func(StreamEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	// 1イベントが既定のバッファ（64KB）を超える場合がある
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var event StreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+		if err := handle(event); err != nil {
+			if err == errStreamEnd {
+				return nil
+			}
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return errors.New("dify stream ended before completion")
+}
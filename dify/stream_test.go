@@ -0,0 +1,205 @@
+package dify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sse イベントを Server-Sent Events の形式に並べます
+func sse(events ...string) string {
+	var b strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&b, "data: %s\n\n", event)
+	}
+	return b.String()
+}
+
+func TestReadAnswer(t *testing.T) {
+	tests := []struct {
+		name           string
+		stream         string
+		deltas         []string
+		answer         string
+		conversationID string
+		err            string
+	}{
+		{
+			name: "message",
+			stream: sse(
+				`{"event":"message","task_id":"t1","message_id":"m1","conversation_id":"c1","answer":"Hello"}`,
+				`{"event":"message","task_id":"t1","message_id":"m1","conversation_id":"c1","answer":", world"}`,
+				`{"event":"message_end","conversation_id":"c1"}`,
+			),
+			deltas:         []string{"Hello", ", world"},
+			answer:         "Hello, world",
+			conversationID: "c1",
+		},
+		{
+			name: "agent message and other events",
+			stream: "event: ping\n\n" + sse(
+				`{"event":"agent_thought","id":"x"}`,
+				`{"event":"agent_message","message_id":"m1","answer":"A"}`,
+				`{"event":"message","message_id":"m1","answer":""}`,
+				`{"event":"message_end","conversation_id":"c2"}`,
+			),
+			deltas:         []string{"A"},
+			answer:         "A",
+			conversationID: "c2",
+		},
+		{
+			name: "large event",
+			stream: sse(
+				`{"event":"message","answer":"`+strings.Repeat("a", 100*1024)+`"}`,
+				`{"event":"message_end"}`,
+			),
+			deltas: []string{strings.Repeat("a", 100*1024)},
+			answer: strings.Repeat("a", 100*1024),
+		},
+		{
+			name: "error event",
+			stream: sse(
+				`{"event":"message","answer":"A"}`,
+				`{"event":"error","status":400,"code":"invalid_param","message":"bad"}`,
+			),
+			deltas: []string{"A"},
+			err:    "status 400, code invalid_param: bad",
+		},
+		{
+			name:   "ended before completion",
+			stream: sse(`{"event":"message","answer":"A"}`),
+			deltas: []string{"A"},
+			err:    "ended before completion",
+		},
+		{
+			name:   "malformed event",
+			stream: "data: {\n\n",
+			err:    "failed to unmarshal stream event",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deltas []string
+			response, err := readAnswer(strings.NewReader(tt.stream), func(delta string) error {
+				deltas = append(deltas, delta)
+				return nil
+			})
+			if !reflect.DeepEqual(deltas, tt.deltas) {
+				t.Errorf("deltas = %q, want %q", deltas, tt.deltas)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readAnswer: %v", err)
+			}
+			if response.Answer != tt.answer || response.ConversationID != tt.conversationID {
+				t.Errorf("response = %q in %q, want %q in %q", response.Answer, response.ConversationID, tt.answer, tt.conversationID)
+			}
+		})
+	}
+}
+
+func TestReadAnswerStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	_, err := readAnswer(strings.NewReader(sse(
+		`{"event":"message","answer":"A"}`,
+		`{"event":"message","answer":"B"}`,
+		`{"event":"message_end"}`,
+	)), func(delta string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want the callback error after 1 call", err, calls)
+	}
+}
+
+func TestReadEventsStreamError(t *testing.T) {
+	err := readEvents(strings.NewReader(sse(`{"event":"error","status":500,"code":"internal","message":"boom"}`)), func(event StreamEvent) error {
+		return &StreamError{Status: event.Status, Code: event.Code, Message: event.Message}
+	})
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || streamErr.Status != 500 || streamErr.Code != "internal" {
+		t.Errorf("err = %v, want a StreamError with status 500", err)
+	}
+}
+
+func TestStreamWorkflow(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		texts  []string
+		output interface{}
+		err    string
+	}{
+		{
+			name: "succeeded",
+			stream: sse(
+				`{"event":"workflow_started","workflow_run_id":"r1","task_id":"t1"}`,
+				`{"event":"node_started","data":{"id":"n1"}}`,
+				`{"event":"text_chunk","data":{"text":"Hel"}}`,
+				`{"event":"text_chunk","data":{"text":"lo"}}`,
+				`{"event":"workflow_finished","data":{"status":"succeeded","outputs":{"answer":"Hello"}}}`,
+			),
+			texts:  []string{"Hel", "lo"},
+			output: "Hello",
+		},
+		{
+			name: "failed",
+			stream: sse(
+				`{"event":"workflow_started","workflow_run_id":"r1"}`,
+				`{"event":"workflow_finished","data":{"status":"failed","error":"node failed"}}`,
+			),
+			err: "dify workflow failed: node failed",
+		},
+		{
+			name:   "malformed text chunk",
+			stream: sse(`{"event":"text_chunk","data":{"text":1}}`),
+			err:    "failed to unmarshal text chunk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/workflows/run" || r.Header.Get("Authorization") != "Bearer token" {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tt.stream)
+			}))
+			defer server.Close()
+
+			var texts []string
+			result, err := StreamWorkflow(context.Background(), server.URL, "token", RunRequest{User: "u1"}, func(delta string) error {
+				texts = append(texts, delta)
+				return nil
+			})
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("texts = %q, want %q", texts, tt.texts)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StreamWorkflow: %v", err)
+			}
+			if result.WorkflowRunID != "r1" || result.Data.Outputs["answer"] != tt.output {
+				t.Errorf("result = %+v, want run r1 with answer %v", result, tt.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package dify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// WorkflowSucceeded 正常に終了したワークフローの状態
+const WorkflowSucceeded = "succeeded"
+
+// WorkflowResult ワークフローの実行結果
+type WorkflowResult struct {
+	WorkflowRunID string       `json:"workflow_run_id"`
+	TaskID        string       `json:"task_id"`
+	Data          WorkflowData `json:"data"`
+}
+
+type WorkflowData struct {
+	ID         string `json:"id"`
+	WorkflowID string `json:"workflow_id"`
+	// Status "running"、"succeeded"、"failed"、"stopped"
+	Status      string                 `json:"status"`
+	Outputs     map[string]interface{} `json:"outputs"`
+	Error       string                 `json:"error"`
+	ElapsedTime float64                `json:"elapsed_time"`
+	TotalTokens int                    `json:"total_tokens"`
+}
+
+// RunWorkflow ワークフローを実行し、終了を待って結果を返します
+// ワークフローが成功しなかった場合はエラーを返します
+func RunWorkflow(ctx context.Context, baseUrl string, token string, body RunRequest) (*WorkflowResult, error) {
+	body.ResponseMode = ResponseModeBlocking
+	if body.Inputs == nil {
+		body.Inputs = map[string]interface{}{}
+	}
+
+	resp, err := post(ctx, baseUrl+"/v1/workflows/run", token, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result WorkflowResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if err := result.Data.err(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StreamWorkflow ストリーミングモードでワークフローを実行します
+// 回答ノードなどのテキストが届くたびに onText が呼ばれます
+func StreamWorkflow(ctx context.Context, baseUrl string, token string, body RunRequest, onText func(delta string) error) (*WorkflowResult, error) {
+	body.ResponseMode = ResponseModeStreaming
+	if body.Inputs == nil {
+		body.Inputs = map[string]interface{}{}
+	}
+
+	resp, err := post(ctx, baseUrl+"/v1/workflows/run", token, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &WorkflowResult{}
+	err = readEvents(resp.Body, func(event StreamEvent) error {
+		switch event.Event {
+		case EventWorkflowStarted:
+			result.WorkflowRunID = event.WorkflowRunID
+			result.TaskID = event.TaskID
+		case EventTextChunk:
+			var chunk struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(event.Data, &chunk); err != nil {
+				return fmt.Errorf("failed to unmarshal text chunk: %w", err)
+			}
+			if chunk.Text != "" {
+				return onText(chunk.Text)
+			}
+		case EventWorkflowFinished:
+			if err := json.Unmarshal(event.Data, &result.Data); err != nil {
+				return fmt.Errorf("failed to unmarshal workflow result: %w", err)
+			}
+			return errStreamEnd
+		case EventError:
+			return &StreamError{Status: event.Status, Code: event.Code, Message: event.Message}
+		}
+		// node_started などの途中経過は無視する
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Data.err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (d WorkflowData) err() error {
+	if d.Status == WorkflowSucceeded {
+		return nil
+	}
+	if d.Error != "" {
+		return fmt.Errorf("dify workflow %s: %s", d.Status, d.Error)
+	}
+	return fmt.Errorf("dify workflow %s", d.Status)
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"context"
 	"discord-bot-service/bot"
+	"discord-bot-service/internal/repository/mongodb"
 	"discord-bot-service/internal/service"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -13,11 +15,12 @@ import (
 
 type BotHandler struct {
 	service        *service.BotService
+	botFlowService *service.BotFlowService
 	sessionService *bot.BotManager
 }
 
-func NewBotHandler(service *service.BotService, sessionService *bot.BotManager) *BotHandler {
-	return &BotHandler{service: service, sessionService: sessionService}
+func NewBotHandler(service *service.BotService, botFlowService *service.BotFlowService, sessionService *bot.BotManager) *BotHandler {
+	return &BotHandler{service: service, botFlowService: botFlowService, sessionService: sessionService}
 }
 
 func (h *BotHandler) GetAllBots(c *gin.Context) {
@@ -46,6 +49,11 @@ func (h *BotHandler) AddBot(c *gin.Context) {
 		return
 	}
 
+	// ボット名をキーとする既存のフローを紐づけに移行してから起動する
+	if err := h.botFlowService.MigrateLegacyFlows(c.Request.Context(), *bot); err != nil {
+		log.Printf("Failed to migrate flows for bot %s: %v", bot.ID, err)
+	}
+
 	// BotManagerにボットを追加
 	if err := h.sessionService.AddBot(input.Token); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start bot session"})
@@ -69,18 +77,100 @@ func (h *BotHandler) DeleteBot(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func SetupBotRoutes(r *gin.Engine, botService *service.BotService, flowService *service.FlowDataService, nodeExe *bot.FlowExecutor, apiURL string) {
-	sessionService := bot.NewBotManager(flowService, nodeExe, apiURL)
-	handler := NewBotHandler(botService, sessionService)
+func (h *BotHandler) GetBotFlows(c *gin.Context) {
+	bindings, err := h.botFlowService.GetBindings(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bindings)
+}
+
+func (h *BotHandler) AttachFlow(c *gin.Context) {
+	var input struct {
+		FlowKey  string `json:"flowKey" binding:"required"`
+		Enabled  *bool  `json:"enabled"`
+		Priority int    `json:"priority"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enabled := input.Enabled == nil || *input.Enabled
+	botID := c.Param("id")
+	binding, err := h.botFlowService.AttachFlow(c.Request.Context(), botID, input.FlowKey, enabled, input.Priority)
+	if err != nil {
+		if errors.Is(err, mongodb.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "flow not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.sessionService.ReloadFlows(botID)
+	c.JSON(http.StatusCreated, binding)
+}
+
+func (h *BotHandler) UpdateBotFlow(c *gin.Context) {
+	var input struct {
+		Enabled  *bool `json:"enabled"`
+		Priority *int  `json:"priority"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	botID := c.Param("id")
+	binding, err := h.botFlowService.UpdateBinding(c.Request.Context(), botID, c.Param("key"), input.Enabled, input.Priority)
+	if err != nil {
+		if errors.Is(err, mongodb.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.sessionService.ReloadFlows(botID)
+	c.JSON(http.StatusOK, binding)
+}
+
+func (h *BotHandler) DetachFlow(c *gin.Context) {
+	botID := c.Param("id")
+	if err := h.botFlowService.DetachFlow(c.Request.Context(), botID, c.Param("key")); err != nil {
+		if errors.Is(err, mongodb.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.sessionService.ReloadFlows(botID)
+	c.Status(http.StatusNoContent)
+}
+
+func SetupBotRoutes(r *gin.Engine, botService *service.BotService, botFlowService *service.BotFlowService, sessionService *bot.BotManager) {
+	handler := NewBotHandler(botService, botFlowService, sessionService)
 
 	r.GET("/bot", handler.GetAllBots)
 	r.POST("/bot", handler.AddBot)
 	r.DELETE("/bots/:id", handler.DeleteBot)
-	go initializeBots(botService, sessionService)
+	r.GET("/bots/:id/flows", handler.GetBotFlows)
+	r.POST("/bots/:id/flows", handler.AttachFlow)
+	r.PATCH("/bots/:id/flows/:key", handler.UpdateBotFlow)
+	r.DELETE("/bots/:id/flows/:key", handler.DetachFlow)
+	go initializeBots(botService, botFlowService, sessionService)
 
 }
 
-func initializeBots(botService *service.BotService, sessionManager *bot.BotManager) {
+func initializeBots(botService *service.BotService, botFlowService *service.BotFlowService, sessionManager *bot.BotManager) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
@@ -91,6 +181,9 @@ func initializeBots(botService *service.BotService, sessionManager *bot.BotManag
 	}
 
 	for _, bot := range bots {
+		if err := botFlowService.MigrateLegacyFlows(ctx, bot); err != nil {
+			log.Printf("Failed to migrate flows for bot %s: %v", bot.ID, err)
+		}
 
 		err := sessionManager.AddBot(bot.Token)
 		if err != nil {
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"discord-bot-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DifyConversationHandler struct {
+	service *service.DifyConversationService
+}
+
+func NewDifyConversationHandler(service *service.DifyConversationService) *DifyConversationHandler {
+	return &DifyConversationHandler{service: service}
+}
+
+func (h *DifyConversationHandler) ListConversations(c *gin.Context) {
+	channelID := c.Query("channelId")
+	if channelID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channelId is required"})
+		return
+	}
+
+	conversations, err := h.service.ListConversations(c.Request.Context(), channelID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, conversations)
+}
+
+// ResetConversations チャンネルで使われた会話を削除します。app を指定した場合はそのアプリの会話のみ
+// 削除される会話は DifyConversationService.ResetConversations を参照してください
+func (h *DifyConversationHandler) ResetConversations(c *gin.Context) {
+	channelID := c.Query("channelId")
+	if channelID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channelId is required"})
+		return
+	}
+
+	deleted, err := h.service.ResetConversations(c.Request.Context(), channelID, c.Query("app"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+func SetupDifyConversationRoutes(r *gin.Engine, service *service.DifyConversationService) {
+	handler := NewDifyConversationHandler(service)
+
+	r.GET("/dify/conversations", handler.ListConversations)
+	r.DELETE("/dify/conversations", handler.ResetConversations)
+}
@@ -1,19 +1,27 @@
 package api
 
 import (
+	"discord-bot-service/bot"
 	"discord-bot-service/internal/models"
+	"discord-bot-service/internal/repository/mongodb"
 	"discord-bot-service/internal/service"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/gin-gonic/gin"
 )
 
 type FlowDataHandler struct {
-	service *service.FlowDataService
+	service  *service.FlowDataService
+	executor *bot.FlowExecutor
+	bots     *bot.BotManager
 }
 
-func NewFlowDataHandler(service *service.FlowDataService) *FlowDataHandler {
-	return &FlowDataHandler{service: service}
+func NewFlowDataHandler(service *service.FlowDataService, executor *bot.FlowExecutor, bots *bot.BotManager) *FlowDataHandler {
+	return &FlowDataHandler{service: service, executor: executor, bots: bots}
 }
 
 func (h *FlowDataHandler) SaveFlowData(c *gin.Context) {
@@ -24,6 +32,15 @@ func (h *FlowDataHandler) SaveFlowData(c *gin.Context) {
 	}
 
 	if err := h.service.SaveFlowData(c.Request.Context(), &flowData); err != nil {
+		var validationErr *service.FlowValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "errors": validationErr.Errors})
+			return
+		}
+		if errors.Is(err, service.ErrFlowConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -31,9 +48,20 @@ func (h *FlowDataHandler) SaveFlowData(c *gin.Context) {
 	c.JSON(http.StatusOK, flowData)
 }
 
+func (h *FlowDataHandler) ValidateFlowData(c *gin.Context) {
+	var flowData models.FlowData
+	if err := c.ShouldBindJSON(&flowData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	errs := h.service.ValidateFlowData(c.Request.Context(), &flowData)
+	c.JSON(http.StatusOK, gin.H{"valid": len(errs) == 0, "errors": errs})
+}
+
 func (h *FlowDataHandler) GetFlowData(c *gin.Context) {
-	id := c.Param("id")
-	flowData, err := h.service.GetFlowData(c.Request.Context(), id)
+	key := c.Param("key")
+	flowData, err := h.service.GetFlowData(c.Request.Context(), key)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,11 +90,185 @@ func (h *FlowDataHandler) DeleteFlowData(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func SetupFlowDataRoutes(r *gin.Engine, service *service.FlowDataService) {
-	handler := NewFlowDataHandler(service)
+// SimulateFlowData 実際のDiscordに接続せず、疑似メッセージでフローを実行します
+// Difyへの問い合わせや会話の保存は行わず、仮の回答で代用します
+func (h *FlowDataHandler) SimulateFlowData(c *gin.Context) {
+	var input struct {
+		BotID     string `json:"botId"`
+		GuildID   string `json:"guildId"`
+		ChannelID string `json:"channelId" binding:"required"`
+		Author    struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"author"`
+		Content  string   `json:"content"`
+		Mentions []string `json:"mentions"`
+		// TriggerType 反応させるトリガーノードのタイプ（省略時はメンション）
+		TriggerType string                 `json:"triggerType"`
+		Command     string                 `json:"command"`
+		Options     map[string]interface{} `json:"options"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	flowData, err := h.service.GetFlowData(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		if errors.Is(err, mongodb.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.BotID == "" {
+		input.BotID = "simulated-bot"
+	}
+	if input.Author.ID == "" {
+		input.Author.ID = "simulated-user"
+	}
+	mentions := make([]*discordgo.User, 0, len(input.Mentions))
+	for _, id := range input.Mentions {
+		mentions = append(mentions, &discordgo.User{ID: id})
+	}
+	message := &discordgo.Message{
+		ID:        "simulated-trigger",
+		GuildID:   input.GuildID,
+		ChannelID: input.ChannelID,
+		Content:   input.Content,
+		Timestamp: time.Now(),
+		Author:    &discordgo.User{ID: input.Author.ID, Username: input.Author.Username},
+		Mentions:  mentions,
+	}
+
+	if input.TriggerType == "" {
+		input.TriggerType = bot.TriggerMention
+	}
+	trigger := bot.NewMessageTrigger(input.TriggerType, message)
+	trigger.CommandName = input.Command
+	trigger.Options = input.Options
+
+	client := bot.NewFakeClient(input.BotID)
+	client.AddUser(message.Author)
+	client.AddMessage(message)
+	// Difyへの問い合わせや会話の保存など、外部への副作用はシミュレーション中は行わない
+	exec, err := h.executor.ExecuteFlow(bot.WithSimulation(c.Request.Context()), *flowData, trigger, client)
+
+	response := gin.H{
+		"trace":     exec.Trace(),
+		"actions":   client.Actions(),
+		"variables": exec.Variables.Snapshot(),
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *FlowDataHandler) ListVersions(c *gin.Context) {
+	versions, err := h.service.ListVersions(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondFlowError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func (h *FlowDataHandler) GetVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+		return
+	}
+
+	flowVersion, err := h.service.GetVersion(c.Request.Context(), c.Param("key"), version)
+	if err != nil {
+		respondFlowError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flowVersion)
+}
+
+func (h *FlowDataHandler) DiffVersions(c *gin.Context) {
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be version numbers"})
+		return
+	}
+
+	diff, err := h.service.DiffVersions(c.Request.Context(), c.Param("key"), from, to)
+	if err != nil {
+		respondFlowError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+func (h *FlowDataHandler) PublishVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+		return
+	}
+
+	if err := h.service.PublishVersion(c.Request.Context(), c.Param("key"), version); err != nil {
+		respondFlowError(c, err)
+		return
+	}
+	h.bots.ReloadFlowBots(c.Param("key"))
+
+	c.JSON(http.StatusOK, gin.H{"key": c.Param("key"), "publishedVersion": version})
+}
+
+func (h *FlowDataHandler) Rollback(c *gin.Context) {
+	var input struct {
+		Version int `json:"version" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	flowData, err := h.service.Rollback(c.Request.Context(), c.Param("key"), input.Version)
+	if err != nil {
+		respondFlowError(c, err)
+		return
+	}
+	h.bots.ReloadFlowBots(flowData.Key)
+
+	c.JSON(http.StatusOK, flowData)
+}
+
+func respondFlowError(c *gin.Context, err error) {
+	if errors.Is(err, mongodb.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrFlowConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func SetupFlowDataRoutes(r *gin.Engine, service *service.FlowDataService, executor *bot.FlowExecutor, bots *bot.BotManager) {
+	handler := NewFlowDataHandler(service, executor, bots)
 
 	r.POST("/flow-data", handler.SaveFlowData)
-	r.GET("/flow-data/:id", handler.GetFlowData)
+	r.POST("/flow-data/validate", handler.ValidateFlowData)
+	r.POST("/flow-data/:key/simulate", handler.SimulateFlowData)
+	r.GET("/flow-data/:key", handler.GetFlowData)
+	r.GET("/flow-data/:key/versions", handler.ListVersions)
+	r.GET("/flow-data/:key/versions/:version", handler.GetVersion)
+	r.GET("/flow-data/:key/diff", handler.DiffVersions)
+	r.POST("/flow-data/:key/versions/:version/publish", handler.PublishVersion)
+	r.POST("/flow-data/:key/rollback", handler.Rollback)
 	r.GET("/flow-data", handler.GetAllFlowData)
 	r.DELETE("/flow-data/:id", handler.DeleteFlowData)
 }
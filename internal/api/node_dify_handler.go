@@ -30,6 +30,8 @@ func (h *NodeDifyHandler) AddNodeDify(c *gin.Context) {
 		Name  string `json:"name" binding:"required"`
 		Token string `json:"token" binding:"required"`
 		Url   string `json:"url" binding:"required"`
+		// AppType chat、completion、workflow のいずれか。省略時は chat
+		AppType string `json:"appType" binding:"omitempty,oneof=chat completion workflow"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -37,7 +39,7 @@ func (h *NodeDifyHandler) AddNodeDify(c *gin.Context) {
 		return
 	}
 
-	dify, err := h.service.AddNodeDify(c.Request.Context(), input.Name, input.Token, input.Url)
+	dify, err := h.service.AddNodeDify(c.Request.Context(), input.Name, input.Token, input.Url, input.AppType)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"discord-bot-service/bot"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NodeTypeHandler struct {
+	executor *bot.FlowExecutor
+}
+
+func NewNodeTypeHandler(executor *bot.FlowExecutor) *NodeTypeHandler {
+	return &NodeTypeHandler{executor: executor}
+}
+
+// ListNodeTypes 登録済みノードタイプと設定スキーマの一覧を返します
+func (h *NodeTypeHandler) ListNodeTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, h.executor.NodeTypes())
+}
+
+func SetupNodeTypeRoutes(r *gin.Engine, executor *bot.FlowExecutor) {
+	handler := NewNodeTypeHandler(executor)
+
+	r.GET("/node-types", handler.ListNodeTypes)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"discord-bot-service/internal/models"
+	"discord-bot-service/internal/repository/mongodb"
+	"discord-bot-service/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FlowRunHandler struct {
+	service *service.FlowRunService
+}
+
+func NewFlowRunHandler(service *service.FlowRunService) *FlowRunHandler {
+	return &FlowRunHandler{service: service}
+}
+
+func (h *FlowRunHandler) ListRuns(c *gin.Context) {
+	filter := models.FlowRunFilter{
+		BotID:     c.Query("botId"),
+		FlowKey:   c.Query("flowKey"),
+		ChannelID: c.Query("channelId"),
+		Status:    c.Query("status"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	runs, err := h.service.ListRuns(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+func (h *FlowRunHandler) GetRun(c *gin.Context) {
+	run, err := h.service.GetRun(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, mongodb.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, mongodb.ErrInvalidID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+func SetupRunRoutes(r *gin.Engine, service *service.FlowRunService) {
+	handler := NewFlowRunHandler(service)
+
+	r.GET("/runs", handler.ListRuns)
+	r.GET("/runs/:id", handler.GetRun)
+}
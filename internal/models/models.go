@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,6 +16,8 @@ type Bot struct {
 	Avatar string  `bson:"avatar" json:"avatar"`
 	Token  string  `bson:"token" json:"-"`
 	Guilds []Guild `bson:"guilds" json:"guilds"`
+	// FlowsMigrated ボット名をキーとするフローを紐づけに移行済みかどうか
+	FlowsMigrated bool `bson:"flowsMigrated" json:"-"`
 }
 
 type Guild struct {
@@ -29,11 +32,50 @@ type Channel struct {
 	Name string `bson:"name" json:"name"`
 }
 
+// BotFlow ボットとフローの紐づけ
+type BotFlow struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BotID   string             `bson:"botId" json:"botId"`
+	FlowKey string             `bson:"flowKey" json:"flowKey"`
+	Enabled bool               `bson:"enabled" json:"enabled"`
+	// Priority 値が大きいフローから順に評価されます
+	Priority  int       `bson:"priority" json:"priority"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Difyアプリの種類
+const (
+	DifyAppChat       = "chat"
+	DifyAppCompletion = "completion"
+	DifyAppWorkflow   = "workflow"
+)
+
 type NodeDify struct {
 	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name  string             `bson:"name" json:"name"`
 	Token string             `bson:"token" json:"-"`
 	Url   string             `bson:"url" json:"url"`
+	// AppType 呼び出すAPIを決めるアプリの種類。未設定の旧データは chat
+	AppType string `bson:"appType,omitempty" json:"appType"`
+}
+
+// DifyConversation Difyアプリとの会話の紐づけ
+// 有効期限を過ぎた会話は削除され、次の問い合わせから新しい会話になります
+type DifyConversation struct {
+	ID  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	App string             `bson:"app" json:"app"`
+	// Scope 会話を共有する単位のキー
+	Scope string `bson:"scope" json:"scope"`
+	// ChannelIDs 会話が使われたチャンネル。チャンネルをリセットすると、そのチャンネルを含む会話が削除されます
+	// ユーザー単位の会話は複数のチャンネルにまたがり、それ以外は会話のキーに含まれるチャンネルだけになります
+	ChannelIDs []string `bson:"channelIds" json:"channelIds"`
+	// UserID 最後に問い合わせたユーザー
+	UserID         string    `bson:"userId" json:"userId"`
+	ConversationID string    `bson:"conversationId" json:"conversationId"`
+	CreatedAt      time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time `bson:"updatedAt" json:"updatedAt"`
+	ExpiresAt      time.Time `bson:"expiresAt" json:"expiresAt"`
 }
 
 type FlowData struct {
@@ -41,6 +83,84 @@ type FlowData struct {
 	Key   string             `bson:"key" json:"key"`
 	Edges []Edge             `bson:"edges" json:"edges"`
 	Nodes []Node             `bson:"nodes" json:"nodes"`
+	// Version 最後に保存されたバージョン番号（未保存の旧データは0）
+	Version int `bson:"version" json:"version"`
+	// PublishedVersion ボットが実行する公開中のバージョン番号（未公開は0）
+	PublishedVersion int          `bson:"publishedVersion" json:"publishedVersion"`
+	Settings         FlowSettings `bson:"settings" json:"settings"`
+}
+
+// 分岐の実行方法
+const (
+	ExecutionSequential = "sequential"
+	ExecutionParallel   = "parallel"
+)
+
+// FlowSettings フロー全体の実行設定
+type FlowSettings struct {
+	// ExecutionMode 分岐先のノードを順番に実行するか並行して実行するか。空の場合は sequential
+	ExecutionMode string `bson:"executionMode,omitempty" json:"executionMode,omitempty"`
+	// MaxParallelism parallel の場合に同時に実行する分岐の上限。0の場合は既定値
+	MaxParallelism int `bson:"maxParallelism,omitempty" json:"maxParallelism,omitempty"`
+}
+
+// フローのバージョンの状態
+const (
+	FlowVersionDraft     = "draft"
+	FlowVersionPublished = "published"
+	FlowVersionArchived  = "archived"
+)
+
+// FlowVersion 保存ごとに作成される変更不可のフローのスナップショット
+type FlowVersion struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FlowKey   string             `bson:"flowKey" json:"flowKey"`
+	Version   int                `bson:"version" json:"version"`
+	Edges     []Edge             `bson:"edges,omitempty" json:"edges,omitempty"`
+	Nodes     []Node             `bson:"nodes,omitempty" json:"nodes,omitempty"`
+	Settings  FlowSettings       `bson:"settings" json:"settings"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	// Status 公開中のバージョンとの比較で決まるため保存しない
+	Status string `bson:"-" json:"status"`
+}
+
+// FlowDiff 2つのバージョン間の差分
+type FlowDiff struct {
+	From  int      `json:"from"`
+	To    int      `json:"to"`
+	Nodes NodeDiff `json:"nodes"`
+	Edges EdgeDiff `json:"edges"`
+	// Settings 実行設定が変更された場合のみ設定されます
+	Settings *SettingsChange `json:"settings,omitempty"`
+}
+
+type SettingsChange struct {
+	Before FlowSettings `json:"before"`
+	After  FlowSettings `json:"after"`
+}
+
+type NodeDiff struct {
+	Added   []Node       `json:"added"`
+	Removed []Node       `json:"removed"`
+	Changed []NodeChange `json:"changed"`
+}
+
+type NodeChange struct {
+	ID     string `json:"id"`
+	Before Node   `json:"before"`
+	After  Node   `json:"after"`
+}
+
+type EdgeDiff struct {
+	Added   []Edge       `json:"added"`
+	Removed []Edge       `json:"removed"`
+	Changed []EdgeChange `json:"changed"`
+}
+
+type EdgeChange struct {
+	ID     string `json:"id"`
+	Before Edge   `json:"before"`
+	After  Edge   `json:"after"`
 }
 
 func EnsureIndexes(ctx context.Context, collection *mongo.Collection) error {
@@ -55,9 +175,13 @@ func EnsureIndexes(ctx context.Context, collection *mongo.Collection) error {
 }
 
 type Edge struct {
-	ID        string `bson:"id" json:"id"`
-	Source    string `bson:"source" json:"source"`
-	Target    string `bson:"target" json:"target"`
+	ID     string `bson:"id" json:"id"`
+	Source string `bson:"source" json:"source"`
+	Target string `bson:"target" json:"target"`
+	// SourceHandle 接続元ノードの出力ハンドル名（"true"/"false"/"default"など）
+	SourceHandle string `bson:"sourceHandle,omitempty" json:"sourceHandle,omitempty"`
+	// Condition 実行時変数に対する条件式。空の場合は常に遷移する
+	Condition string `bson:"condition,omitempty" json:"condition,omitempty"`
 	Deletable bool   `bson:"deletable" json:"deletable"`
 }
 
@@ -70,10 +194,69 @@ type Node struct {
 }
 
 type NodeData struct {
-	Label string `bson:"label" json:"label"`
+	Label  string                 `bson:"label" json:"label"`
+	Config map[string]interface{} `bson:"config,omitempty" json:"config,omitempty"`
+	// Timeout ノードの実行時間の上限（秒）。0の場合は既定値を使用します
+	Timeout float64 `bson:"timeout,omitempty" json:"timeout,omitempty"`
 }
 
 type NodePosition struct {
 	X int `bson:"x" json:"x"`
 	Y int `bson:"y" json:"y"`
 }
+
+// ValidationError フロー検証で見つかったノード・エッジ単位のエラー
+type ValidationError struct {
+	NodeID  string `json:"nodeId,omitempty"`
+	EdgeID  string `json:"edgeId,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// フロー実行の状態
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+	RunStatusTimeout   = "timeout"
+	RunStatusCanceled  = "canceled"
+)
+
+// FlowRun 1回のフロー実行の記録
+type FlowRun struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BotID            string             `bson:"botId" json:"botId"`
+	FlowKey          string             `bson:"flowKey" json:"flowKey"`
+	TriggerType      string             `bson:"triggerType" json:"triggerType"`
+	TriggerMessageID string             `bson:"triggerMessageId" json:"triggerMessageId"`
+	GuildID          string             `bson:"guildId" json:"guildId"`
+	ChannelID        string             `bson:"channelId" json:"channelId"`
+	Status           string             `bson:"status" json:"status"`
+	Error            string             `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt        time.Time          `bson:"startedAt" json:"startedAt"`
+	FinishedAt       time.Time          `bson:"finishedAt" json:"finishedAt"`
+	Nodes            []NodeRun          `bson:"nodes" json:"nodes"`
+}
+
+// NodeRun フロー実行中の1ノード分の記録
+type NodeRun struct {
+	NodeID     string                 `bson:"nodeId" json:"nodeId"`
+	Type       string                 `bson:"type" json:"type"`
+	Scope      string                 `bson:"scope,omitempty" json:"scope,omitempty"`
+	Continue   bool                   `bson:"continue" json:"continue"`
+	Handle     string                 `bson:"handle,omitempty" json:"handle,omitempty"`
+	Output     map[string]interface{} `bson:"output,omitempty" json:"output,omitempty"`
+	Error      string                 `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt  time.Time              `bson:"startedAt" json:"startedAt"`
+	FinishedAt time.Time              `bson:"finishedAt" json:"finishedAt"`
+	// Children subflow ノードが呼び出したフロー内のノードの記録
+	Children []NodeRun `bson:"children,omitempty" json:"children,omitempty"`
+}
+
+// FlowRunFilter 実行履歴の検索条件
+type FlowRunFilter struct {
+	BotID     string
+	FlowKey   string
+	ChannelID string
+	Status    string
+	Limit     int
+}
@@ -47,6 +47,12 @@ func (r *BotRepository) GetAll(ctx context.Context) ([]models.Bot, error) {
 	return bots, nil
 }
 
+// SetFlowsMigrated フローの紐づけへの移行が完了したことを記録します
+func (r *BotRepository) SetFlowsMigrated(ctx context.Context, botID string) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"id": botID}, bson.M{"$set": bson.M{"flowsMigrated": true}})
+	return err
+}
+
 func (r *BotRepository) GetByID(ctx context.Context, id string) (*models.Bot, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -0,0 +1,115 @@
+package mongodb
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BotFlowRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBotFlowRepository(db *mongo.Database) BotFlowRepository {
+	return BotFlowRepository{
+		collection: db.Collection("bot_flows"),
+	}
+}
+
+func (r BotFlowRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "botId", Value: 1}, {Key: "flowKey", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	return err
+}
+
+// Save ボットとフローの紐づけを作成または更新します
+func (r BotFlowRepository) Save(ctx context.Context, binding *models.BotFlow) error {
+	now := time.Now()
+	binding.UpdatedAt = now
+
+	var saved models.BotFlow
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"botId": binding.BotID, "flowKey": binding.FlowKey},
+		bson.M{
+			"$set": bson.M{
+				"enabled":   binding.Enabled,
+				"priority":  binding.Priority,
+				"updatedAt": now,
+			},
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"createdAt": now,
+			},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		return err
+	}
+	*binding = saved
+	return nil
+}
+
+func (r BotFlowRepository) Get(ctx context.Context, botID, flowKey string) (*models.BotFlow, error) {
+	var binding models.BotFlow
+	err := r.collection.FindOne(ctx, bson.M{"botId": botID, "flowKey": flowKey}).Decode(&binding)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	return &binding, err
+}
+
+// FindByBot ボットの紐づけを優先度の高い順に返します
+func (r BotFlowRepository) FindByBot(ctx context.Context, botID string) ([]models.BotFlow, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "createdAt", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"botId": botID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	bindings := []models.BotFlow{}
+	if err = cursor.All(ctx, &bindings); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// FindByFlow フローを紐づけているボットの紐づけを返します
+func (r BotFlowRepository) FindByFlow(ctx context.Context, flowKey string) ([]models.BotFlow, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"flowKey": flowKey})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	bindings := []models.BotFlow{}
+	if err = cursor.All(ctx, &bindings); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+func (r BotFlowRepository) Delete(ctx context.Context, botID, flowKey string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"botId": botID, "flowKey": flowKey})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
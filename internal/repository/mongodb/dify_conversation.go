@@ -0,0 +1,130 @@
+package mongodb
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DifyConversationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewDifyConversationRepository(db *mongo.Database) DifyConversationRepository {
+	return DifyConversationRepository{
+		collection: db.Collection("dify_conversations"),
+	}
+}
+
+func (r DifyConversationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "app", Value: 1}, {Key: "scope", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "channelIds", Value: 1}, {Key: "updatedAt", Value: -1}}},
+		// 有効期限を過ぎた会話をMongoDBに削除させる
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+// Get 有効期限内の会話を返します
+// TTLによる削除は定期的にしか行われないため、期限切れの会話もここで除外します
+func (r DifyConversationRepository) Get(ctx context.Context, app, scope string) (*models.DifyConversation, error) {
+	var conversation models.DifyConversation
+	err := r.collection.FindOne(ctx, bson.M{
+		"app":       app,
+		"scope":     scope,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&conversation)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	return &conversation, err
+}
+
+// Save 会話を作成または更新します
+// conversation.ChannelIDs は保存済みのチャンネルに追加されます
+func (r DifyConversationRepository) Save(ctx context.Context, conversation *models.DifyConversation) error {
+	now := time.Now()
+	conversation.UpdatedAt = now
+
+	var saved models.DifyConversation
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"app": conversation.App, "scope": conversation.Scope},
+		bson.M{
+			"$set": bson.M{
+				"userId":         conversation.UserID,
+				"conversationId": conversation.ConversationID,
+				"updatedAt":      now,
+				"expiresAt":      conversation.ExpiresAt,
+			},
+			"$addToSet": bson.M{"channelIds": bson.M{"$each": conversation.ChannelIDs}},
+			// チャンネルを1つだけ保存していた以前の形式から移行する
+			"$unset": bson.M{"channelId": ""},
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"createdAt": now,
+			},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if mongo.IsDuplicateKeyError(err) {
+		// 同じ会話を同時に作成した場合は後から保存した方が負ける
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		return err
+	}
+	*conversation = saved
+	return nil
+}
+
+// channelQuery チャンネルで使われた会話の条件。以前の形式で保存された会話も対象にします
+func channelQuery(channelID string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"channelIds": channelID},
+		bson.M{"channelId": channelID},
+	}}
+}
+
+// FindByChannel チャンネルで使われた有効期限内の会話を新しい順に返します
+func (r DifyConversationRepository) FindByChannel(ctx context.Context, channelID string) ([]models.DifyConversation, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}})
+	query := channelQuery(channelID)
+	query["expiresAt"] = bson.M{"$gt": time.Now()}
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	conversations := []models.DifyConversation{}
+	if err = cursor.All(ctx, &conversations); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// DeleteByChannel チャンネルで使われた会話を削除し、削除した件数を返します
+// app が空の場合はすべてのアプリの会話を削除します
+func (r DifyConversationRepository) DeleteByChannel(ctx context.Context, channelID, app string) (int64, error) {
+	query := channelQuery(channelID)
+	if app != "" {
+		query["app"] = app
+	}
+	result, err := r.collection.DeleteMany(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
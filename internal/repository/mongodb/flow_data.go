@@ -4,6 +4,7 @@ import (
 	"context"
 	"discord-bot-service/internal/models"
 	"errors"
+	"regexp"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,6 +15,7 @@ import (
 var (
 	ErrDuplicateKey = errors.New("duplicate key error")
 	ErrNotFound     = errors.New("document not found")
+	ErrInvalidID    = errors.New("invalid id")
 )
 
 type FlowDataRepository struct {
@@ -37,6 +39,8 @@ func (r FlowDataRepository) EnsureIndexes(ctx context.Context) error {
 	return err
 }
 
+// Save フローを保存します
+// 保存済みのフローのバージョンが flow.Version 以上の場合は上書きせず ErrDuplicateKey を返します
 func (r FlowDataRepository) Save(ctx context.Context, flow *models.FlowData) error {
 	data, err := r.GetByKey(ctx, flow.Key)
 	if err != nil && err != ErrNotFound {
@@ -49,8 +53,9 @@ func (r FlowDataRepository) Save(ctx context.Context, flow *models.FlowData) err
 		flow.ID = data.ID
 	}
 
+	// 一致しない場合は挿入しようとしてキーの一意制約で失敗する
 	_, err = r.collection.UpdateOne(ctx,
-		bson.M{"key": flow.Key},
+		bson.M{"key": flow.Key, "version": bson.M{"$lt": flow.Version}},
 		bson.M{"$set": flow},
 		options.Update().SetUpsert(true),
 	)
@@ -61,6 +66,21 @@ func (r FlowDataRepository) Save(ctx context.Context, flow *models.FlowData) err
 	return err
 }
 
+// SetPublishedVersion 公開するバージョン番号を設定します
+func (r FlowDataRepository) SetPublishedVersion(ctx context.Context, key string, version int) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"key": key},
+		bson.M{"$set": bson.M{"publishedVersion": version}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (r FlowDataRepository) GetByID(ctx context.Context, id string) (*models.FlowData, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -84,6 +104,24 @@ func (r FlowDataRepository) GetByKey(ctx context.Context, key string) (*models.F
 	return &flow, err
 }
 
+// FindByBotName ボット名と一致するキー、または "ボット名/" で始まるキーのフローを返します
+func (r FlowDataRepository) FindByBotName(ctx context.Context, botName string) ([]models.FlowData, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"key": botName},
+		bson.M{"key": bson.M{"$regex": "^" + regexp.QuoteMeta(botName+"/")}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	flows := []models.FlowData{}
+	if err = cursor.All(ctx, &flows); err != nil {
+		return nil, err
+	}
+	return flows, nil
+}
+
 func (r FlowDataRepository) GetAll(ctx context.Context) ([]models.FlowData, error) {
 	cursor, err := r.collection.Find(ctx, bson.M{})
 	if err != nil {
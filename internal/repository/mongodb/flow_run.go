@@ -0,0 +1,104 @@
+package mongodb
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultFlowRunLimit = 50
+	maxFlowRunLimit     = 500
+	// flowRunRetention 実行履歴を保持する期間。開始から期間が過ぎた履歴はTTLインデックスで削除されます
+	flowRunRetention = 30 * 24 * time.Hour
+)
+
+type FlowRunRepository struct {
+	collection *mongo.Collection
+}
+
+func NewFlowRunRepository(db *mongo.Database) FlowRunRepository {
+	return FlowRunRepository{
+		collection: db.Collection("flow_runs"),
+	}
+}
+
+func (r FlowRunRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "botId", Value: 1}, {Key: "startedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "flowKey", Value: 1}, {Key: "startedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "channelId", Value: 1}, {Key: "startedAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "startedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(flowRunRetention / time.Second)),
+		},
+	})
+	return err
+}
+
+func (r FlowRunRepository) Create(ctx context.Context, run *models.FlowRun) error {
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, run)
+	return err
+}
+
+func (r FlowRunRepository) GetByID(ctx context.Context, id string) (*models.FlowRun, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var run models.FlowRun
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	return &run, err
+}
+
+// Find 条件に一致する実行履歴を新しい順に返します
+func (r FlowRunRepository) Find(ctx context.Context, filter models.FlowRunFilter) ([]models.FlowRun, error) {
+	query := bson.M{}
+	if filter.BotID != "" {
+		query["botId"] = filter.BotID
+	}
+	if filter.FlowKey != "" {
+		query["flowKey"] = filter.FlowKey
+	}
+	if filter.ChannelID != "" {
+		query["channelId"] = filter.ChannelID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultFlowRunLimit
+	}
+	if limit > maxFlowRunLimit {
+		limit = maxFlowRunLimit
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "startedAt", Value: -1}}).
+		SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []models.FlowRun{}
+	if err = cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,89 @@
+package mongodb
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FlowVersionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewFlowVersionRepository(db *mongo.Database) FlowVersionRepository {
+	return FlowVersionRepository{
+		collection: db.Collection("flow_versions"),
+	}
+}
+
+func (r FlowVersionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "flowKey", Value: 1}, {Key: "version", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	return err
+}
+
+func (r FlowVersionRepository) Create(ctx context.Context, version *models.FlowVersion) error {
+	if version.ID.IsZero() {
+		version.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, version)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r FlowVersionRepository) Get(ctx context.Context, flowKey string, version int) (*models.FlowVersion, error) {
+	var v models.FlowVersion
+	err := r.collection.FindOne(ctx, bson.M{"flowKey": flowKey, "version": version}).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	return &v, err
+}
+
+// Latest フローの最新のバージョン番号を返します（バージョンがない場合は0）
+func (r FlowVersionRepository) Latest(ctx context.Context, flowKey string) (int, error) {
+	var v models.FlowVersion
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetProjection(bson.M{"version": 1})
+	err := r.collection.FindOne(ctx, bson.M{"flowKey": flowKey}, opts).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return v.Version, err
+}
+
+// List フローのバージョンを新しい順に返します（ノードとエッジは含みません）
+func (r FlowVersionRepository) List(ctx context.Context, flowKey string) ([]models.FlowVersion, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetProjection(bson.M{"nodes": 0, "edges": 0})
+	cursor, err := r.collection.Find(ctx, bson.M{"flowKey": flowKey}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := []models.FlowVersion{}
+	if err = cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// DeleteByFlow フローのすべてのバージョンを削除します
+func (r FlowVersionRepository) DeleteByFlow(ctx context.Context, flowKey string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"flowKey": flowKey})
+	return err
+}
@@ -9,13 +9,22 @@ type Repository struct {
 	FlowData FlowDataRepository
 	Bot      BotRepository
 	NodeDify NodeDifyRepository
+	FlowRun  FlowRunRepository
+	BotFlow  BotFlowRepository
+	Versions FlowVersionRepository
+	// Conversations Difyアプリとの会話
+	Conversations DifyConversationRepository
 }
 
 func NewRepository(db *mongo.Database) *Repository {
 	return &Repository{
-		db:       db,
-		FlowData: NewFlowDataRepository(db),
-		NodeDify: NewNodeDifyRepository(db),
-		Bot:      NewBotRepository(db),
+		db:            db,
+		FlowData:      NewFlowDataRepository(db),
+		NodeDify:      NewNodeDifyRepository(db),
+		Bot:           NewBotRepository(db),
+		FlowRun:       NewFlowRunRepository(db),
+		BotFlow:       NewBotFlowRepository(db),
+		Versions:      NewFlowVersionRepository(db),
+		Conversations: NewDifyConversationRepository(db),
 	}
 }
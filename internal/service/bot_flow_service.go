@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"discord-bot-service/internal/repository/mongodb"
+	"errors"
+	"log"
+)
+
+type BotFlowService struct {
+	repo        mongodb.BotFlowRepository
+	bots        mongodb.BotRepository
+	flowService *FlowDataService
+}
+
+func NewBotFlowService(repo *mongodb.Repository, flowService *FlowDataService) *BotFlowService {
+	return &BotFlowService{repo: repo.BotFlow, bots: repo.Bot, flowService: flowService}
+}
+
+func (s *BotFlowService) GetBindings(ctx context.Context, botID string) ([]models.BotFlow, error) {
+	return s.repo.FindByBot(ctx, botID)
+}
+
+// AttachFlow フローをボットに紐づけます。既に紐づいている場合は設定を更新します
+func (s *BotFlowService) AttachFlow(ctx context.Context, botID, flowKey string, enabled bool, priority int) (*models.BotFlow, error) {
+	if _, err := s.flowService.GetFlowData(ctx, flowKey); err != nil {
+		return nil, err
+	}
+
+	binding := &models.BotFlow{
+		BotID:    botID,
+		FlowKey:  flowKey,
+		Enabled:  enabled,
+		Priority: priority,
+	}
+	if err := s.repo.Save(ctx, binding); err != nil {
+		return nil, err
+	}
+	return binding, nil
+}
+
+// UpdateBinding 紐づけの有効/無効と優先度を変更します。nil の項目は変更しません
+func (s *BotFlowService) UpdateBinding(ctx context.Context, botID, flowKey string, enabled *bool, priority *int) (*models.BotFlow, error) {
+	binding, err := s.repo.Get(ctx, botID, flowKey)
+	if err != nil {
+		return nil, err
+	}
+	if enabled != nil {
+		binding.Enabled = *enabled
+	}
+	if priority != nil {
+		binding.Priority = *priority
+	}
+	if err := s.repo.Save(ctx, binding); err != nil {
+		return nil, err
+	}
+	return binding, nil
+}
+
+// GetFlowBindings フローを紐づけているボットの紐づけを返します
+func (s *BotFlowService) GetFlowBindings(ctx context.Context, flowKey string) ([]models.BotFlow, error) {
+	return s.repo.FindByFlow(ctx, flowKey)
+}
+
+func (s *BotFlowService) DetachFlow(ctx context.Context, botID, flowKey string) error {
+	return s.repo.Delete(ctx, botID, flowKey)
+}
+
+// MigrateLegacyFlows ボット名をキーとするフローを、ボットIDでの紐づけに移行します
+// 移行はボットごとに一度だけ行い、その後に外された紐づけは復元しません
+func (s *BotFlowService) MigrateLegacyFlows(ctx context.Context, bot models.Bot) error {
+	if bot.FlowsMigrated {
+		return nil
+	}
+	flows, err := s.flowService.FindLegacyBotFlows(ctx, bot.Name)
+	if err != nil {
+		return err
+	}
+	for _, flow := range flows {
+		_, err := s.repo.Get(ctx, bot.ID, flow.Key)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongodb.ErrNotFound) {
+			return err
+		}
+		binding := &models.BotFlow{BotID: bot.ID, FlowKey: flow.Key, Enabled: true}
+		if err := s.repo.Save(ctx, binding); err != nil {
+			return err
+		}
+		log.Printf("Migrated flow %s to bot %s", flow.Key, bot.ID)
+	}
+	return s.bots.SetFlowsMigrated(ctx, bot.ID)
+}
+
+// GetBotFlows ボットで実行する有効なフローを優先度の高い順に返します
+func (s *BotFlowService) GetBotFlows(ctx context.Context, botID string) ([]models.FlowData, error) {
+	bindings, err := s.repo.FindByBot(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := []models.FlowData{}
+	for _, binding := range bindings {
+		if !binding.Enabled {
+			continue
+		}
+		flow, err := s.flowService.GetPublishedFlow(ctx, binding.FlowKey)
+		if errors.Is(err, mongodb.ErrNotFound) || errors.Is(err, ErrFlowNotPublished) {
+			log.Printf("Skipping flow %s attached to bot %s: %v", binding.FlowKey, botID, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, *flow)
+	}
+	return flows, nil
+}
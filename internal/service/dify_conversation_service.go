@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"discord-bot-service/internal/repository/mongodb"
+	"errors"
+	"time"
+)
+
+// defaultConversationTTL 最後の問い合わせから会話を保持する期間
+const defaultConversationTTL = 7 * 24 * time.Hour
+
+type DifyConversationService struct {
+	repo mongodb.DifyConversationRepository
+	// TTL 最後の問い合わせから会話を保持する期間
+	TTL time.Duration
+}
+
+func NewDifyConversationService(repo *mongodb.Repository) *DifyConversationService {
+	return &DifyConversationService{repo: repo.Conversations, TTL: defaultConversationTTL}
+}
+
+// GetConversationID 続きから会話するための会話IDを返します
+// 会話がない、または有効期限が切れている場合は空文字を返します
+func (s *DifyConversationService) GetConversationID(ctx context.Context, app, scope string) (string, error) {
+	conversation, err := s.repo.Get(ctx, app, scope)
+	if errors.Is(err, mongodb.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return conversation.ConversationID, nil
+}
+
+// SaveConversation 会話IDを保存し、有効期限を延長します
+// channelID は会話を使ったチャンネルで、会話が使われたチャンネルとして記録されます
+func (s *DifyConversationService) SaveConversation(ctx context.Context, app, scope, channelID, userID, conversationID string) error {
+	return s.repo.Save(ctx, &models.DifyConversation{
+		App:            app,
+		Scope:          scope,
+		ChannelIDs:     []string{channelID},
+		UserID:         userID,
+		ConversationID: conversationID,
+		ExpiresAt:      time.Now().Add(s.TTL),
+	})
+}
+
+// ListConversations チャンネルで使われ、続いている会話を返します
+// ユーザー単位の会話は、そのユーザーが問い合わせたすべてのチャンネルで表示されます
+func (s *DifyConversationService) ListConversations(ctx context.Context, channelID string) ([]models.DifyConversation, error) {
+	return s.repo.FindByChannel(ctx, channelID)
+}
+
+// ResetConversations チャンネルで使われた会話を削除し、次の問い合わせから新しい会話にします
+// チャンネル単位・チャンネル内のユーザー単位の会話に加えて、そのチャンネルで使われたユーザー単位の会話も削除します
+// スレッドはスレッドのチャンネルIDで指定します
+func (s *DifyConversationService) ResetConversations(ctx context.Context, channelID, app string) (int64, error) {
+	return s.repo.DeleteByChannel(ctx, channelID, app)
+}
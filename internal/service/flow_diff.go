@@ -0,0 +1,89 @@
+package service
+
+import (
+	"discord-bot-service/internal/models"
+	"encoding/json"
+)
+
+// diffFlows ノードとエッジをIDで突き合わせて差分を作成します
+// ノードの位置など表示上の変更は差分に含めません
+func diffFlows(before, after *models.FlowVersion) *models.FlowDiff {
+	diff := &models.FlowDiff{
+		From: before.Version,
+		To:   after.Version,
+		Nodes: models.NodeDiff{
+			Added:   []models.Node{},
+			Removed: []models.Node{},
+			Changed: []models.NodeChange{},
+		},
+		Edges: models.EdgeDiff{
+			Added:   []models.Edge{},
+			Removed: []models.Edge{},
+			Changed: []models.EdgeChange{},
+		},
+	}
+
+	beforeNodes := make(map[string]models.Node, len(before.Nodes))
+	for _, node := range before.Nodes {
+		beforeNodes[node.ID] = node
+	}
+	afterNodes := make(map[string]bool, len(after.Nodes))
+	for _, node := range after.Nodes {
+		afterNodes[node.ID] = true
+		old, ok := beforeNodes[node.ID]
+		switch {
+		case !ok:
+			diff.Nodes.Added = append(diff.Nodes.Added, node)
+		case old.Type != node.Type || !sameJSON(old.Data, node.Data):
+			diff.Nodes.Changed = append(diff.Nodes.Changed, models.NodeChange{ID: node.ID, Before: old, After: node})
+		}
+	}
+	for _, node := range before.Nodes {
+		if !afterNodes[node.ID] {
+			diff.Nodes.Removed = append(diff.Nodes.Removed, node)
+		}
+	}
+
+	beforeEdges := make(map[string]models.Edge, len(before.Edges))
+	for _, edge := range before.Edges {
+		beforeEdges[edgeKey(edge)] = edge
+	}
+	afterEdges := make(map[string]bool, len(after.Edges))
+	for _, edge := range after.Edges {
+		key := edgeKey(edge)
+		afterEdges[key] = true
+		old, ok := beforeEdges[key]
+		switch {
+		case !ok:
+			diff.Edges.Added = append(diff.Edges.Added, edge)
+		case old.Source != edge.Source || old.Target != edge.Target ||
+			old.SourceHandle != edge.SourceHandle || old.Condition != edge.Condition:
+			diff.Edges.Changed = append(diff.Edges.Changed, models.EdgeChange{ID: key, Before: old, After: edge})
+		}
+	}
+	for _, edge := range before.Edges {
+		if !afterEdges[edgeKey(edge)] {
+			diff.Edges.Removed = append(diff.Edges.Removed, edge)
+		}
+	}
+
+	if before.Settings != after.Settings {
+		diff.Settings = &models.SettingsChange{Before: before.Settings, After: after.Settings}
+	}
+
+	return diff
+}
+
+// edgeKey IDのないエッジは接続元と接続先で識別します
+func edgeKey(edge models.Edge) string {
+	if edge.ID != "" {
+		return edge.ID
+	}
+	return edge.Source + "->" + edge.Target + ":" + edge.SourceHandle
+}
+
+func sameJSON(a, b interface{}) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aj) == string(bj)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"discord-bot-service/internal/models"
+	"discord-bot-service/internal/repository/mongodb"
+)
+
+type FlowRunService struct {
+	repo mongodb.FlowRunRepository
+}
+
+func NewFlowRunService(repo *mongodb.Repository) *FlowRunService {
+	return &FlowRunService{repo: repo.FlowRun}
+}
+
+func (s *FlowRunService) RecordRun(ctx context.Context, run *models.FlowRun) error {
+	return s.repo.Create(ctx, run)
+}
+
+func (s *FlowRunService) GetRun(ctx context.Context, id string) (*models.FlowRun, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *FlowRunService) ListRuns(ctx context.Context, filter models.FlowRunFilter) ([]models.FlowRun, error) {
+	return s.repo.Find(ctx, filter)
+}
@@ -4,28 +4,261 @@ import (
 	"context"
 	"discord-bot-service/internal/models"
 	"discord-bot-service/internal/repository/mongodb"
+	"errors"
+	"fmt"
+	"time"
 )
 
+// FlowValidator 保存前にフローを検証するインターフェース
+type FlowValidator interface {
+	ValidateFlow(ctx context.Context, flow models.FlowData) []models.ValidationError
+}
+
+// FlowValidationError フローの検証に失敗した場合のエラー
+type FlowValidationError struct {
+	Errors []models.ValidationError
+}
+
+func (e *FlowValidationError) Error() string {
+	return fmt.Sprintf("flow validation failed with %d error(s)", len(e.Errors))
+}
+
+var (
+	// ErrFlowNotPublished 公開中のバージョンがないフローを実行しようとした場合のエラー
+	ErrFlowNotPublished = errors.New("flow has no published version")
+	// ErrFlowConflict 同じフローへの保存が同時に行われ、保存できなかった場合のエラー
+	ErrFlowConflict = errors.New("flow was saved concurrently, reload and try again")
+)
+
+// maxSaveAttempts 同時に保存されてバージョン番号が重複した場合に保存を試みる回数
+const maxSaveAttempts = 3
+
 type FlowDataService struct {
-	repo mongodb.FlowDataRepository
+	repo      mongodb.FlowDataRepository
+	versions  mongodb.FlowVersionRepository
+	validator FlowValidator
 }
 
-func NewFlowDataService(repo *mongodb.Repository) *FlowDataService {
-	return &FlowDataService{repo: repo.FlowData}
+func NewFlowDataService(repo *mongodb.Repository, validator FlowValidator) *FlowDataService {
+	return &FlowDataService{repo: repo.FlowData, versions: repo.Versions, validator: validator}
 }
 
+// SaveFlowData フローを検証し、新しい下書きバージョンとして保存します
+// 公開中のバージョンは変更されません
 func (s *FlowDataService) SaveFlowData(ctx context.Context, flowData *models.FlowData) error {
+	if errs := s.ValidateFlowData(ctx, flowData); len(errs) > 0 {
+		return &FlowValidationError{Errors: errs}
+	}
+	return s.saveVersion(ctx, flowData)
+}
+
+// saveVersion フローを新しいバージョンとして保存します
+// 同時に保存されてバージョン番号が重複した場合は、番号を振り直して保存し直します
+func (s *FlowDataService) saveVersion(ctx context.Context, flowData *models.FlowData) error {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		err := s.trySaveVersion(ctx, flowData)
+		if !errors.Is(err, mongodb.ErrDuplicateKey) {
+			return err
+		}
+	}
+	return ErrFlowConflict
+}
+
+func (s *FlowDataService) trySaveVersion(ctx context.Context, flowData *models.FlowData) error {
+	current, err := s.repo.GetByKey(ctx, flowData.Key)
+	if err != nil && !errors.Is(err, mongodb.ErrNotFound) {
+		return err
+	}
+	// 先に保存されたバージョンがフローにまだ反映されていない場合もあるため、バージョンの一覧から番号を決める
+	latest, err := s.versions.Latest(ctx, flowData.Key)
+	if err != nil {
+		return err
+	}
+	flowData.PublishedVersion = 0
+	if current != nil && current.Version == 0 {
+		// バージョン管理導入前のフローは、実行中の内容をバージョン1として公開してから下書きを保存する
+		if latest == 0 {
+			if err := s.createVersion(ctx, current, 1); err != nil {
+				return err
+			}
+			latest = 1
+		}
+		flowData.PublishedVersion = 1
+	} else if current != nil {
+		flowData.PublishedVersion = current.PublishedVersion
+	}
+	flowData.Version = latest + 1
+
+	if err := s.createVersion(ctx, flowData, flowData.Version); err != nil {
+		return err
+	}
 	return s.repo.Save(ctx, flowData)
 }
 
+func (s *FlowDataService) createVersion(ctx context.Context, flowData *models.FlowData, version int) error {
+	return s.versions.Create(ctx, &models.FlowVersion{
+		FlowKey:   flowData.Key,
+		Version:   version,
+		Edges:     flowData.Edges,
+		Nodes:     flowData.Nodes,
+		Settings:  flowData.Settings,
+		CreatedAt: time.Now(),
+	})
+}
+
+// ValidateFlowData フローを保存せずに検証します
+func (s *FlowDataService) ValidateFlowData(ctx context.Context, flowData *models.FlowData) []models.ValidationError {
+	errs := []models.ValidationError{}
+	if flowData.Key == "" {
+		errs = append(errs, models.ValidationError{Field: "key", Message: "フローのキーが空です"})
+	}
+	if s.validator != nil {
+		errs = append(errs, s.validator.ValidateFlow(ctx, *flowData)...)
+	}
+	return errs
+}
+
+// GetFlowData 編集中（最新の下書き）のフローを返します
 func (s *FlowDataService) GetFlowData(ctx context.Context, key string) (*models.FlowData, error) {
 	return s.repo.GetByKey(ctx, key)
 }
 
+// GetPublishedFlow ボットが実行する公開中のバージョンのフローを返します
+func (s *FlowDataService) GetPublishedFlow(ctx context.Context, key string) (*models.FlowData, error) {
+	flow, err := s.repo.GetByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.published(ctx, flow)
+}
+
+func (s *FlowDataService) published(ctx context.Context, flow *models.FlowData) (*models.FlowData, error) {
+	if flow.PublishedVersion == 0 {
+		// バージョン管理導入前に保存されたフローはそのまま公開中として扱う
+		if flow.Version == 0 {
+			return flow, nil
+		}
+		return nil, ErrFlowNotPublished
+	}
+
+	version, err := s.versions.Get(ctx, flow.Key, flow.PublishedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FlowData{
+		ID:               flow.ID,
+		Key:              flow.Key,
+		Edges:            version.Edges,
+		Nodes:            version.Nodes,
+		Version:          version.Version,
+		PublishedVersion: flow.PublishedVersion,
+		Settings:         version.Settings,
+	}, nil
+}
+
+// FindLegacyBotFlows キーがボット名、または "ボット名/" で始まるフローを返します
+// ボットとフローの紐づけを導入する前に作成されたフローの移行に使用します
+func (s *FlowDataService) FindLegacyBotFlows(ctx context.Context, botName string) ([]models.FlowData, error) {
+	return s.repo.FindByBotName(ctx, botName)
+}
+
+// ListVersions フローのバージョン一覧を新しい順に返します
+func (s *FlowDataService) ListVersions(ctx context.Context, key string) ([]models.FlowVersion, error) {
+	flow, err := s.repo.GetByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.versions.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		versions[i].Status = versionStatus(versions[i].Version, flow.PublishedVersion)
+	}
+	return versions, nil
+}
+
+// GetVersion 特定のバージョンを返します
+func (s *FlowDataService) GetVersion(ctx context.Context, key string, version int) (*models.FlowVersion, error) {
+	flow, err := s.repo.GetByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.versions.Get(ctx, key, version)
+	if err != nil {
+		return nil, err
+	}
+	v.Status = versionStatus(v.Version, flow.PublishedVersion)
+	return v, nil
+}
+
+func versionStatus(version, publishedVersion int) string {
+	switch {
+	case version == publishedVersion:
+		return models.FlowVersionPublished
+	case version > publishedVersion:
+		return models.FlowVersionDraft
+	default:
+		return models.FlowVersionArchived
+	}
+}
+
+// PublishVersion 指定したバージョンを公開し、ボットが実行するフローを切り替えます
+func (s *FlowDataService) PublishVersion(ctx context.Context, key string, version int) error {
+	if _, err := s.versions.Get(ctx, key, version); err != nil {
+		return err
+	}
+	return s.repo.SetPublishedVersion(ctx, key, version)
+}
+
+// Rollback 過去のバージョンの内容を新しいバージョンとして保存し、公開します
+func (s *FlowDataService) Rollback(ctx context.Context, key string, version int) (*models.FlowData, error) {
+	v, err := s.versions.Get(ctx, key, version)
+	if err != nil {
+		return nil, err
+	}
+
+	flowData := &models.FlowData{
+		Key:      key,
+		Edges:    v.Edges,
+		Nodes:    v.Nodes,
+		Settings: v.Settings,
+	}
+	if err := s.saveVersion(ctx, flowData); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetPublishedVersion(ctx, key, flowData.Version); err != nil {
+		return nil, err
+	}
+	flowData.PublishedVersion = flowData.Version
+	return flowData, nil
+}
+
+// DiffVersions 2つのバージョン間で追加・削除・変更されたノードとエッジを返します
+func (s *FlowDataService) DiffVersions(ctx context.Context, key string, from, to int) (*models.FlowDiff, error) {
+	before, err := s.versions.Get(ctx, key, from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.versions.Get(ctx, key, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffFlows(before, after), nil
+}
+
 func (s *FlowDataService) GetAllFlowData(ctx context.Context) ([]models.FlowData, error) {
 	return s.repo.GetAll(ctx)
 }
 
+// DeleteFlowData フローとそのすべてのバージョンを削除します
 func (s *FlowDataService) DeleteFlowData(ctx context.Context, id string) error {
-	return s.repo.Delete(ctx, id)
+	flow, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.versions.DeleteByFlow(ctx, flow.Key)
 }
@@ -23,11 +23,16 @@ func (s *NodeDifyService) GetNodeDifyByName(ctx context.Context, name string) (*
 	return s.repo.GetByName(ctx, name)
 }
 
-func (s *NodeDifyService) AddNodeDify(ctx context.Context, name, token, url string) (*models.NodeDify, error) {
+// AddNodeDify Difyアプリを登録します。appType が空の場合は chat として登録します
+func (s *NodeDifyService) AddNodeDify(ctx context.Context, name, token, url, appType string) (*models.NodeDify, error) {
+	if appType == "" {
+		appType = models.DifyAppChat
+	}
 	dify := &models.NodeDify{
-		Name:  name,
-		Token: token,
-		Url:   url,
+		Name:    name,
+		Token:   token,
+		Url:     url,
+		AppType: appType,
 	}
 
 	if err := s.repo.Create(ctx, dify); err != nil {